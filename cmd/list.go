@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/config"
+	"github.com/Scalify/website-content-watcher/pkg/watcher"
+	"github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list <config-file>",
+	Short: "List all jobs with their schedule and next run",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := logrus.New()
+
+		if len(args) < 1 {
+			if err := cmd.Usage(); err != nil {
+				logger.Fatal(err)
+			}
+			os.Exit(1)
+		}
+
+		configFile, err := filepath.Abs(args[0])
+		if err != nil {
+			logger.Fatalf("failed to resolve config file path: %v", err)
+		}
+
+		conf, err := config.Load(configFile)
+		if err != nil {
+			logger.Fatalf("failed to load config from %q: %v", configFile, err)
+		}
+
+		w := watcher.New(logger.WithFields(logrus.Fields{}), nil, nil, configFile, conf)
+		schedules, err := w.Schedules(time.Now())
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tSCHEDULE\tTIMEZONE\tNEXT RUN")
+		for _, s := range schedules {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Name, s.Schedule, s.Location, s.Next.Format("2006-01-02 15:04:05 MST"))
+		}
+
+		if err := tw.Flush(); err != nil {
+			logger.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(listCmd)
+}
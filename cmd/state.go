@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/config"
+	"github.com/Scalify/website-content-watcher/pkg/storage"
+	"github.com/Scalify/website-content-watcher/pkg/watcher"
+	"github.com/Sirupsen/logrus"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gcGrace    time.Duration
+	gcDryRun   bool
+	importFile string
+)
+
+// stateCmd represents the state command
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Inspect and maintain the stored state of jobs",
+}
+
+// stateGCCmd represents the state gc command
+var stateGCCmd = &cobra.Command{
+	Use:   "gc <config-file>",
+	Short: "Delete the state of jobs removed from the config",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := logrus.New()
+		requireArgs(logger, cmd, args, 1)
+
+		w := newStateWatcher(logger, args[0])
+		removed, err := w.CollectGarbage(gcGrace, gcDryRun)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		if len(removed) == 0 {
+			fmt.Println("No state of removed jobs found.")
+			return
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "JOB\tLAST SEEN\tKEYS")
+		for _, job := range removed {
+			fmt.Fprintf(tw, "%s\t%s\t%d\n", job.Key, job.LastSeen.Format("2006-01-02 15:04:05 MST"), len(job.Keys))
+		}
+		if err := tw.Flush(); err != nil {
+			logger.Fatal(err)
+		}
+
+		if gcDryRun {
+			fmt.Println("Dry run, nothing was deleted.")
+		}
+	},
+}
+
+// stateShowCmd represents the state show command
+var stateShowCmd = &cobra.Command{
+	Use:   "show <config-file> <job>",
+	Short: "Print the stored values of a job",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := logrus.New()
+		requireArgs(logger, cmd, args, 2)
+
+		values, err := newStateWatcher(logger, args[0]).State(args[1])
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		var items []string
+		for item := range values {
+			items = append(items, item)
+		}
+		sort.Strings(items)
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ITEM\tVALUE")
+		for _, item := range items {
+			fmt.Fprintf(tw, "%s\t%s\n", item, values[item])
+		}
+		if err := tw.Flush(); err != nil {
+			logger.Fatal(err)
+		}
+	},
+}
+
+// stateResetCmd represents the state reset command
+var stateResetCmd = &cobra.Command{
+	Use:   "reset <config-file> <job> [item...]",
+	Short: "Delete the stored values of a job or some of its items",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := logrus.New()
+		requireArgs(logger, cmd, args, 2)
+
+		if err := newStateWatcher(logger, args[0]).ResetState(args[1], args[2:]); err != nil {
+			logger.Fatal(err)
+		}
+	},
+}
+
+// stateSetCmd represents the state set command
+var stateSetCmd = &cobra.Command{
+	Use:   "set <config-file> <job> <item> <value>",
+	Short: "Store the value of an item of a job",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := logrus.New()
+		requireArgs(logger, cmd, args, 4)
+
+		if err := newStateWatcher(logger, args[0]).SetState(args[1], args[2], args[3]); err != nil {
+			logger.Fatal(err)
+		}
+	},
+}
+
+// stateExportCmd represents the state export command
+var stateExportCmd = &cobra.Command{
+	Use:   "export <config-file> [job...]",
+	Short: "Print the stored values of all or the given jobs as JSON",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := logrus.New()
+		requireArgs(logger, cmd, args, 1)
+
+		state, err := newStateWatcher(logger, args[0]).ExportState(args[1:])
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(state); err != nil {
+			logger.Fatalf("failed to encode state: %v", err)
+		}
+	},
+}
+
+// stateImportCmd represents the state import command
+var stateImportCmd = &cobra.Command{
+	Use:   "import <config-file> [job...]",
+	Short: "Replace the stored values of all or the given jobs by exported JSON",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := logrus.New()
+		requireArgs(logger, cmd, args, 1)
+
+		var in io.Reader = os.Stdin
+		if importFile != "" && importFile != "-" {
+			f, err := os.Open(importFile)
+			if err != nil {
+				logger.Fatalf("failed to open %q: %v", importFile, err)
+			}
+			defer f.Close()
+			in = f
+		}
+
+		var state watcher.JobState
+		if err := json.NewDecoder(in).Decode(&state); err != nil {
+			logger.Fatalf("failed to decode state: %v", err)
+		}
+
+		if len(args) > 1 {
+			selected := make(watcher.JobState)
+			for _, name := range args[1:] {
+				values, ok := state[name]
+				if !ok {
+					logger.Fatalf("job %q is not part of the imported state", name)
+				}
+				selected[name] = values
+			}
+			state = selected
+		}
+
+		imported, err := newStateWatcher(logger, args[0]).ImportState(state)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		for _, name := range imported {
+			fmt.Printf("Imported %d values of job %q\n", len(state[name]), name)
+		}
+	},
+}
+
+// requireArgs prints the usage and exits if less than n arguments are given
+func requireArgs(logger *logrus.Logger, cmd *cobra.Command, args []string, n int) {
+	if len(args) >= n {
+		return
+	}
+
+	if err := cmd.Usage(); err != nil {
+		logger.Fatal(err)
+	}
+	os.Exit(1)
+}
+
+// newStateWatcher creates a watcher connected to redis only, as the state commands don't execute jobs
+func newStateWatcher(logger *logrus.Logger, file string) *watcher.Watcher {
+	var cfg RedisEnv
+	if err := envconfig.Process("", &cfg); err != nil {
+		logger.Fatal(err)
+	}
+
+	configFile, err := filepath.Abs(file)
+	if err != nil {
+		logger.Fatalf("failed to resolve config file path: %v", err)
+	}
+
+	conf, err := config.Load(configFile)
+	if err != nil {
+		logger.Fatalf("failed to load config from %q: %v", configFile, err)
+	}
+
+	redisClient := storage.NewRedis(connectRedis(logger, cfg))
+	return watcher.New(logger.WithFields(logrus.Fields{}), redisClient, nil, configFile, conf)
+}
+
+func init() {
+	stateGCCmd.Flags().DurationVar(&gcGrace, "grace", watcher.DefaultGCGrace, "keep the state of removed jobs run within this period")
+	stateGCCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "only list the state that would be deleted")
+
+	stateImportCmd.Flags().StringVarP(&importFile, "file", "f", "-", "read the state from this file instead of stdin")
+
+	stateCmd.AddCommand(stateGCCmd, stateShowCmd, stateResetCmd, stateSetCmd, stateExportCmd, stateImportCmd)
+	RootCmd.AddCommand(stateCmd)
+}
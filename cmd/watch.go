@@ -4,11 +4,15 @@
 package cmd
 
 import (
-	"fmt"
+	"encoding/json"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/Scalify/puppet-master-client-go"
+	"github.com/Scalify/website-content-watcher/pkg/api"
 	"github.com/Scalify/website-content-watcher/pkg/config"
 	"github.com/Scalify/website-content-watcher/pkg/mail"
 	"github.com/Scalify/website-content-watcher/pkg/notifier"
@@ -19,26 +23,47 @@ import (
 	"github.com/kelseyhightower/envconfig"
 	"github.com/robfig/cron"
 	"github.com/spf13/cobra"
-	"gopkg.in/gomail.v2"
 )
 
+// RedisEnv configures the redis connection
+type RedisEnv struct {
+	RedisURL                   string   `required:"false" split_words:"true" envconfig:"REDIS_URL"`
+	RedisDb                    int      `default:"0" split_words:"true"`
+	RedisPort                  int      `default:"6379" split_words:"true"`
+	RedisHost                  string   `required:"false" split_words:"true"`
+	RedisUsername              string   `required:"false" split_words:"true"`
+	RedisPassword              string   `required:"false" split_words:"true"`
+	RedisTLS                   bool     `default:"false" split_words:"true" envconfig:"REDIS_TLS"`
+	RedisTLSCAFile             string   `required:"false" split_words:"true" envconfig:"REDIS_TLS_CA_FILE"`
+	RedisTLSInsecureSkipVerify bool     `default:"false" split_words:"true" envconfig:"REDIS_TLS_INSECURE_SKIP_VERIFY"`
+	RedisSentinelMaster        string   `required:"false" split_words:"true"`
+	RedisSentinelAddrs         []string `required:"false" split_words:"true"`
+	RedisClusterAddrs          []string `required:"false" split_words:"true"`
+}
+
 type env struct {
-	RedisDb              int    `required:"true" split_words:"true"`
-	RedisPort            int    `required:"true" split_words:"true"`
-	RedisHost            string `required:"true" split_words:"true"`
-	PuppetMasterEndpoint string `required:"true" split_words:"true"`
-	PuppetMasterAPIToken string `required:"true" split_words:"true" envconfig:"PUPPET_MASTER_API_TOKEN"`
-	MailNotifierEnabled  bool   `default:"false" split_words:"true"`
-	Verbose              bool   `default:"false" split_words:"true"`
-	SingleExecution      bool   `default:"false" split_words:"true"`
+	RedisEnv
+	PuppetMasterEndpoint string        `required:"false" split_words:"true"`
+	PuppetMasterAPIToken string        `required:"false" split_words:"true" envconfig:"PUPPET_MASTER_API_TOKEN"`
+	MailNotifierEnabled  bool          `default:"false" split_words:"true"`
+	Verbose              bool          `default:"false" split_words:"true"`
+	SingleExecution      bool          `default:"false" split_words:"true"`
+	MetricsAddress       string        `required:"false" split_words:"true"`
+	ReadyAddress         string        `default:":8080" split_words:"true"`
+	RedisBufferSize      int           `default:"1000" split_words:"true"`
+	RedisRetryInterval   time.Duration `default:"10s" split_words:"true"`
+	FixtureDir           string        `required:"false" split_words:"true"`
+	FixtureCycle         bool          `default:"false" split_words:"true"`
 }
 
 type mailEnv struct {
-	SMTPHost          string `required:"true" split_words:"true" envconfig:"SMTP_HOST"`
-	SMTPPort          int    `required:"true" split_words:"true" envconfig:"SMTP_PORT"`
-	SMTPUser          string `required:"false" split_words:"true" envconfig:"SMTP_USER"`
-	SMTPPass          string `required:"false" split_words:"true" envconfig:"SMTP_PASS"`
-	MailSenderAddress string `required:"false" split_words:"true"`
+	SMTPHost          string        `required:"true" split_words:"true" envconfig:"SMTP_HOST"`
+	SMTPPort          int           `required:"true" split_words:"true" envconfig:"SMTP_PORT"`
+	SMTPUser          string        `required:"false" split_words:"true" envconfig:"SMTP_USER"`
+	SMTPPass          string        `required:"false" split_words:"true" envconfig:"SMTP_PASS"`
+	SMTPTLSMode       string        `required:"false" split_words:"true" envconfig:"SMTP_TLS_MODE"`
+	SMTPTimeout       time.Duration `default:"10s" split_words:"true" envconfig:"SMTP_TIMEOUT"`
+	MailSenderAddress string        `required:"false" split_words:"true"`
 }
 
 // watchCmd represents the watch command
@@ -63,11 +88,7 @@ var watchCmd = &cobra.Command{
 		setupLogger(logger, cfg.Verbose)
 		c := cron.New()
 
-		redisClient := storage.NewRedis(connectRedis(logger, cfg))
-		pmClient, err := puppetmaster.NewClient(cfg.PuppetMasterEndpoint, cfg.PuppetMasterAPIToken)
-		if err != nil {
-			logger.Fatalf("failed to connect puppet master: %v", err)
-		}
+		store := newStorage(logger, cfg)
 
 		configFile, err := filepath.Abs(args[0])
 		if err != nil {
@@ -79,55 +100,237 @@ var watchCmd = &cobra.Command{
 			logger.Fatalf("failed to load config from %q: %v", configFile, err)
 		}
 
-		w := watcher.New(logger.WithFields(logrus.Fields{}), redisClient, pmClient, configFile, conf)
+		if cfg.FixtureDir != "" {
+			useFixtures(logger, conf, cfg.FixtureDir, cfg.FixtureCycle)
+		}
 
-		addNotifiers(logger, w, cfg)
+		var w *watcher.Watcher
+		if watcher.UsesPuppetMaster(conf) {
+			pmClient := newPuppetMasterClient(logger, cfg, newHTTPClient(watcher.ExecutionTimeout(conf)))
+			w = watcher.New(logger.WithFields(logrus.Fields{}), store, pmClient, configFile, conf)
+		} else {
+			logger.Info("All jobs use the fixture executor, not connecting to puppet-master")
+			w = watcher.New(logger.WithFields(logrus.Fields{}), store, nil, configFile, conf)
+		}
+
+		closers := addNotifiers(logger, w, cfg)
+		defer closeAll(logger, closers)
 
 		if err := w.CheckConfig(); err != nil {
 			logger.Fatal(err)
 		}
 
+		if conf.StateGC != nil {
+			if _, err := w.CollectGarbage(w.GCGrace(), false); err != nil {
+				logger.Errorf("failed to collect state garbage: %v", err)
+			}
+		}
+
 		if cfg.SingleExecution {
 			logger.Warn("Executing jobs only once and exit afterwards (SINGLE_EXECUTION=true)")
-			if err := w.RunNow(); err != nil {
+			err := w.RunNow()
+			flushStorage(logger, store)
+			if err != nil {
 				logger.Fatal(err)
 			}
 			return
 		}
 
-		if err := w.RegisterCronJobs(c); err != nil {
+		if err := w.RegisterCronJobs(ctx, c); err != nil {
 			logger.Fatal(err)
 		}
 		c.Start()
 
+		go w.MonitorStorage(ctx, cfg.RedisRetryInterval)
+		http.Handle("/ready", readyHandler(store))
+
+		if cfg.MetricsAddress != "" {
+			go serveMetrics(logger, cfg.MetricsAddress)
+		}
+		if cfg.ReadyAddress != cfg.MetricsAddress {
+			go serveReady(logger, cfg.ReadyAddress, store)
+		}
+
 		logger.Info("Started cron job.")
 
 		<-ctx.Done()
 		logger.Info("Stopping ...")
 		c.Stop()
+		flushStorage(logger, store)
 	},
 }
 
-func addNotifiers(logger *logrus.Logger, w *watcher.Watcher, cfg env) {
+// addNotifiers registers all enabled notifiers and returns the connections to close on shutdown
+func addNotifiers(logger *logrus.Logger, w *watcher.Watcher, cfg env) []io.Closer {
+	var closers []io.Closer
+
 	if cfg.MailNotifierEnabled {
 		var mailCfg mailEnv
 		if err := envconfig.Process("", &mailCfg); err != nil {
 			logger.Fatal(err)
 		}
 
-		mailClient := mail.New(gomail.NewDialer(mailCfg.SMTPHost, mailCfg.SMTPPort, mailCfg.SMTPUser, mailCfg.SMTPPass))
+		dialer, err := mail.NewDialer(mailCfg.SMTPHost, mailCfg.SMTPPort, mailCfg.SMTPUser, mailCfg.SMTPPass, mailCfg.SMTPTLSMode)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		mailClient := mail.New(logger.WithField("notifier", "mail"), dialer, mailCfg.SMTPTimeout)
+		closers = append(closers, mailClient)
+
 		mailNotifier := notifier.NewMail(mailCfg.MailSenderAddress, mailClient)
 		if err := w.AddNotifier(mailNotifier); err != nil {
 			logger.Fatal(err)
 		}
 	}
+
+	return closers
+}
+
+// newPuppetMasterClient returns the puppet-master client sending its requests with httpClient, which requires
+// its endpoint and API token
+func newPuppetMasterClient(logger *logrus.Logger, cfg env, httpClient *http.Client) *puppetmaster.Client {
+	if cfg.PuppetMasterEndpoint == "" || cfg.PuppetMasterAPIToken == "" {
+		logger.Fatal("PUPPET_MASTER_ENDPOINT and PUPPET_MASTER_API_TOKEN are required unless all jobs use the fixture executor")
+	}
+
+	pmClient, err := puppetmaster.NewClient(cfg.PuppetMasterEndpoint, cfg.PuppetMasterAPIToken)
+	if err != nil {
+		logger.Fatalf("failed to connect puppet master: %v", err)
+	}
+	pmClient.SetHTTPClient(httpClient)
+
+	return pmClient
 }
 
-func connectRedis(logger *logrus.Logger, cfg env) *redis.Client {
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%d", cfg.RedisHost, cfg.RedisPort),
-		DB:   cfg.RedisDb,
+// newHTTPClient returns an HTTP client bounding its requests by timeout. Requests of the puppet-master client
+// can't be canceled, so runs abandoned after their timeout would otherwise keep their request open.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// useFixtures makes all jobs read recorded responses from subdirectories of dir instead of executing them
+func useFixtures(logger *logrus.Logger, conf *api.Config, dir string, cycle bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		logger.Fatalf("failed to resolve fixture directory path: %v", err)
+	}
+
+	logger.Warnf("Reading recorded responses from %q instead of executing jobs (FIXTURE_DIR)", dir)
+	conf.Executor = "fixture"
+	conf.Fixture = &api.FixtureConfig{Dir: dir, Cycle: cycle}
+	for i := range conf.Jobs {
+		conf.Jobs[i].Executor = ""
+		conf.Jobs[i].Fixture = nil
+	}
+}
+
+// newStorage connects to redis. Unless executing jobs only once, the watcher starts even if redis is
+// unavailable and buffers its state until redis is back.
+func newStorage(logger *logrus.Logger, cfg env) *storage.Buffered {
+	buffered := storage.NewBuffered(storage.NewRedis(newRedisClient(logger, cfg.RedisEnv)), cfg.RedisBufferSize)
+	if _, err := buffered.Flush(); err != nil {
+		if cfg.SingleExecution {
+			logger.Fatalf("Error connecting to redis: %v", err)
+		}
+		logger.Warnf("Starting without redis, buffering state until it is available: %v", err)
+	}
+
+	return buffered
+}
+
+// flushStorage writes the state buffered during a redis outage before exiting
+func flushStorage(logger *logrus.Logger, store *storage.Buffered) {
+	if _, err := store.Flush(); err != nil {
+		buffer, _ := store.Status()
+		logger.Errorf("failed to flush state, losing %d buffered writes: %v", buffer, err)
+	}
+}
+
+// readyHandler reports whether the watcher is ready, which requires redis to be available
+func readyHandler(buffered *storage.Buffered) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		buffer, err := buffered.Status()
+		status := struct {
+			Ready          bool   `json:"ready"`
+			BufferedWrites int    `json:"buffered_writes"`
+			Error          string `json:"error,omitempty"`
+		}{Ready: err == nil, BufferedWrites: buffer}
+
+		rw.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			status.Error = err.Error()
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		if err := json.NewEncoder(rw).Encode(status); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// serveMetrics serves the expvar metrics at /debug/vars, registered on the default mux by the expvar package,
+// and /ready
+func serveMetrics(logger *logrus.Logger, addr string) {
+	logger.Infof("Serving metrics on %s", addr)
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		logger.Fatalf("failed to serve metrics: %v", err)
+	}
+}
+
+// serveReady serves only /ready, for readiness probes without metrics
+func serveReady(logger *logrus.Logger, addr string, store *storage.Buffered) {
+	mux := http.NewServeMux()
+	mux.Handle("/ready", readyHandler(store))
+
+	logger.Infof("Serving readiness on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logger.Fatalf("failed to serve readiness: %v", err)
+	}
+}
+
+func closeAll(logger *logrus.Logger, closers []io.Closer) {
+	for _, c := range closers {
+		if err := c.Close(); err != nil {
+			logger.Warnf("failed to close connection: %v", err)
+		}
+	}
+}
+
+// newRedisClient returns a redis client without connecting yet
+func newRedisClient(logger *logrus.Logger, cfg RedisEnv) redis.UniversalClient {
+	redisClient, err := storage.NewRedisClient(storage.RedisConfig{
+		URL:                   cfg.RedisURL,
+		Host:                  cfg.RedisHost,
+		Port:                  cfg.RedisPort,
+		DB:                    cfg.RedisDb,
+		Username:              cfg.RedisUsername,
+		Password:              cfg.RedisPassword,
+		TLS:                   cfg.RedisTLS,
+		TLSCAFile:             cfg.RedisTLSCAFile,
+		TLSInsecureSkipVerify: cfg.RedisTLSInsecureSkipVerify,
+		SentinelMaster:        cfg.RedisSentinelMaster,
+		SentinelAddrs:         cfg.RedisSentinelAddrs,
+		ClusterAddrs:          cfg.RedisClusterAddrs,
 	})
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	return redisClient
+}
+
+func connectRedis(logger *logrus.Logger, cfg RedisEnv) redis.UniversalClient {
+	redisClient := newRedisClient(logger, cfg)
 	if pong, err := redisClient.Ping().Result(); err != nil || pong != "PONG" {
 		logger.Fatalf("Error pinging redis: %v --> %v", pong, err)
 	}
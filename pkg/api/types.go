@@ -1,8 +1,63 @@
 package api
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 // Config represents a configuration file
 type Config struct {
 	Jobs []Job `json:"jobs"`
+	// Templates of notifications by notifier key, e.g. "mail"
+	Templates map[string]Templates `json:"templates"`
+	// Digest configures when queued digest notifications are sent
+	Digest *DigestConfig `json:"digest"`
+	// Timezone is the default time zone of job schedules, e.g. "Europe/Berlin". Defaults to the local time zone.
+	Timezone string `json:"timezone"`
+	// Jitter is the default maximum random delay of job runs
+	Jitter Duration `json:"jitter"`
+	// Concurrency limits the puppet-master executions running at the same time
+	Concurrency *ConcurrencyConfig `json:"concurrency"`
+	// Timeout is the default timeout of job runs, defaults to 10 minutes
+	Timeout Duration `json:"timeout"`
+	// StateGC removes the state of jobs no longer configured on startup, if set
+	StateGC *StateGCConfig `json:"state_gc"`
+	// Executor is the default executor of all jobs
+	Executor string `json:"executor"`
+	// Fixture is the default fixture directory of jobs using the fixture executor.
+	// Each job reads its recorded responses from a subdirectory named by its key.
+	Fixture *FixtureConfig `json:"fixture"`
+}
+
+// FixtureConfig configures recorded puppet-master job responses used instead of executing jobs
+type FixtureConfig struct {
+	// Dir contains the responses as JSON files, used one per run in name order
+	Dir string `json:"dir"`
+	// Cycle starts over after the last response, which is repeated otherwise
+	Cycle bool `json:"cycle"`
+}
+
+// StateGCConfig configures the cleanup of the state of removed jobs
+type StateGCConfig struct {
+	// Grace is how long the state of a removed job is kept after its last run
+	Grace Duration `json:"grace"`
+}
+
+// ConcurrencyConfig limits concurrent puppet-master executions. Zero values are unlimited.
+type ConcurrencyConfig struct {
+	// MaxExecutions is the maximum number of executions of all jobs
+	MaxExecutions int `json:"max_executions"`
+	// TagLimits is the maximum number of executions of jobs with a tag, by tag
+	TagLimits map[string]int `json:"tag_limits"`
+	// MaxQueueWait is how long a run waits for a free slot before it is dropped
+	MaxQueueWait Duration `json:"max_queue_wait"`
+}
+
+// DigestConfig defines the schedule of digest notifications
+type DigestConfig struct {
+	Schedule string `json:"schedule"`
 }
 
 // Job entry of a config file. Defines what to execute when.
@@ -14,15 +69,327 @@ type Job struct {
 	CodeFile           string        `json:"code_file"`
 	VarsFile           string        `json:"vars_file"`
 	ModulesDir         string        `json:"modules_dir"`
+	// Items configures single result items by name. The name "*" applies to all items.
+	Items map[string]ItemConfig `json:"items"`
+	// IgnoreItems are stored and shown, but never reported as changed. Entries are exact
+	// names, glob patterns or regular expressions wrapped in slashes, e.g. "/^session_.*$/".
+	IgnoreItems []string `json:"ignore_items"`
+	// ConfirmRuns is the number of consecutive runs a new value has to be observed before it is reported
+	ConfirmRuns int `json:"confirm_runs"`
+	// History keeps the full values of items compared by hash, if set
+	History *HistoryConfig `json:"history"`
+	// Templates overrides the global templates of notifications by notifier key
+	Templates map[string]Templates `json:"templates"`
+	// QuietWindows suppress notifications of all notify entries of the job
+	QuietWindows []QuietWindow `json:"quiet_windows"`
+	// Timezone the schedule is evaluated in, overriding the global one
+	Timezone string `json:"timezone"`
+	// Jitter is the maximum random delay of each run, overriding the global one. "0s" disables it.
+	Jitter *Duration `json:"jitter"`
+	// Spread offsets an @every schedule by a fixed amount derived from the job name
+	Spread bool `json:"spread"`
+	// Tags group jobs for concurrency limits
+	Tags []string `json:"tags"`
+	// Executor is either sync (default), keeping a request open until the job is done,
+	// async, creating the job and polling it until done, or fixture, reading recorded responses
+	Executor string `json:"executor"`
+	// Fixture configures the recorded responses of the fixture executor, overriding the global ones
+	Fixture *FixtureConfig `json:"fixture"`
+	// Timeout limits a whole run, from loading the job to storing its values, overriding the global one
+	Timeout Duration `json:"timeout"`
+	// NotifyOnFailure sends failed runs to all notify entries not using digests
+	NotifyOnFailure bool `json:"notify_on_failure"`
+	// Guards detect broken results, which are not stored
+	Guards *GuardConfig `json:"guards"`
+	// OnInvalid is either reject (default), failing runs with values not matching their item
+	// configuration, or flag, storing and notifying them with a warning
+	OnInvalid string `json:"on_invalid"`
+	// Collections are array results of records compared by identity, by result name
+	Collections map[string]CollectionConfig `json:"collections"`
+	// InitialRun defines how the first run without stored values is reported: notify (default) sends all
+	// items as added, silent only stores them and summary sends a one-time notification about the watched items
+	InitialRun string `json:"initial_run"`
+}
+
+// CollectionConfig declares an array result of records. Each record is an item named "<result>[<identity>]".
+type CollectionConfig struct {
+	// Identity is the field identifying a record across runs, e.g. "id"
+	Identity string `json:"identity"`
+	// Fields limits the compared fields of records, all fields are compared by default
+	Fields []string `json:"fields"`
+}
+
+// GuardConfig defines when results of a job are suspected to be broken. Zero values are not checked.
+type GuardConfig struct {
+	// MinItems is the minimum number of items of a run
+	MinItems int `json:"min_items"`
+	// RequiredItems have to be present and not empty
+	RequiredItems []string `json:"required_items"`
+	// MaxChangedRatio is the maximum fraction of added, changed and removed items in one run, e.g. 0.5
+	MaxChangedRatio float64 `json:"max_changed_ratio"`
+	// AcceptAfter accepts suspected results once they were identical on this many consecutive runs
+	AcceptAfter int `json:"accept_after"`
+}
+
+// QuietWindow is a time window in which notifications are suppressed. It is either
+// a daily window using From and To, or an absolute one using Start and End.
+type QuietWindow struct {
+	// From and To are times of day like "22:00". Windows ending before they start last over midnight.
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Days limits daily windows to the given weekdays, e.g. "mon". A window belongs to the day it starts on.
+	Days []string `json:"days"`
+	// Timezone of daily windows, defaults to the time zone of the job
+	Timezone string `json:"timezone"`
+	// Start and End define an absolute window, e.g. for a known maintenance
+	Start *time.Time `json:"start"`
+	End   *time.Time `json:"end"`
+	// Action is one of drop (default), delay until the window ends or digest to add them to the next digest
+	Action string `json:"action"`
+}
+
+// Templates references template files used to render notifications. Empty fields use the default.
+type Templates struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	// Text is the plain text alternative of the body
+	Text string `json:"text"`
+	// Digest templates are only used from the global templates
+	DigestSubject string `json:"digest_subject"`
+	DigestBody    string `json:"digest_body"`
+	DigestText    string `json:"digest_text"`
+}
+
+// ItemConfig defines how a single result item is handled
+type ItemConfig struct {
+	// Normalize is applied to the value before comparing it with the last known state.
+	// The raw value is still stored and shown in notifications.
+	Normalize []NormalizeStep `json:"normalize"`
+	// Compare is either "value" (default) or "hash". Items compared by hash are stored as
+	// a SHA-256 of the normalized value plus a preview of PreviewLength characters.
+	Compare       string `json:"compare"`
+	PreviewLength int    `json:"preview_length"`
+	// Type is one of string (default), number, boolean, url or currency. Typed values are validated
+	// and compared in a canonical form, e.g. "1,000.50" equals "1000.5".
+	Type string `json:"type"`
+	// Pattern is a regular expression valid values have to match
+	Pattern string `json:"pattern"`
+	// Locale defines the decimal separator of numbers and currency amounts, which is guessed otherwise
+	Locale string `json:"locale"`
+}
+
+// Duration is a time.Duration written as a string like "30s" in config files
+type Duration time.Duration
+
+// UnmarshalJSON decodes a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("invalid duration %s, expected a string like \"30s\"", b)
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %v", s, err)
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
+// MarshalJSON encodes a duration as string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// HistoryConfig limits the history of full values kept for items compared by hash
+type HistoryConfig struct {
+	// MaxEntries is the number of values kept per item
+	MaxEntries int `json:"max_entries"`
+	// MaxValueSize is the size in bytes above which values are not written to the history
+	MaxValueSize int `json:"max_value_size"`
+}
+
+// NormalizeStep is a single step of a normalization chain
+type NormalizeStep struct {
+	// Type is one of trim, collapse_whitespace, lowercase, regex_replace, strip_query_params or number
+	Type string `json:"type"`
+	// Pattern and Replacement are used by regex_replace
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+	// Params are the query parameters removed by strip_query_params. All are removed if empty.
+	Params []string `json:"params"`
+	// Locale defines the decimal separator used by number, e.g. "en" or "de"
+	Locale string `json:"locale"`
 }
 
-// NotifyEntry defines whom to notify on change
+// NotifyEntry defines whom to notify on change. The value is either a plain
+// target like a mail address, or an object holding NotifyOptions.
 type NotifyEntry struct {
-	Type  string `json:"type"`
-	Value string `json:"value"`
+	Type    string
+	Value   string
+	Options NotifyOptions
+	// Digest queues notifications to send them aggregated on the digest schedule
+	Digest bool
+	// QuietWindows suppress notifications of this entry, in addition to the ones of the job
+	QuietWindows []QuietWindow
 }
 
+// NotifyOptions are the structured form of a notify value
+type NotifyOptions struct {
+	To            []string `json:"to"`
+	Cc            []string `json:"cc"`
+	Bcc           []string `json:"bcc"`
+	ReplyTo       string   `json:"reply_to"`
+	SubjectPrefix string   `json:"subject_prefix"`
+}
+
+type notifyEntryJSON struct {
+	Type         string          `json:"type"`
+	Value        json.RawMessage `json:"value"`
+	Digest       bool            `json:"digest"`
+	QuietWindows []QuietWindow   `json:"quiet_windows"`
+}
+
+// UnmarshalJSON decodes a notify entry with either a plain or a structured value
+func (n *NotifyEntry) UnmarshalJSON(b []byte) error {
+	var entry notifyEntryJSON
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return err
+	}
+
+	*n = NotifyEntry{Type: entry.Type, Digest: entry.Digest, QuietWindows: entry.QuietWindows}
+	value := bytes.TrimSpace(entry.Value)
+	if len(value) == 0 || bytes.Equal(value, []byte("null")) {
+		return nil
+	}
+
+	if value[0] == '{' {
+		if err := json.Unmarshal(value, &n.Options); err != nil {
+			return fmt.Errorf("failed to decode options of notify entry %q: %v", entry.Type, err)
+		}
+		return nil
+	}
+
+	return json.Unmarshal(value, &n.Value)
+}
+
+// MarshalJSON encodes a notify entry in the form it was decoded from
+func (n NotifyEntry) MarshalJSON() ([]byte, error) {
+	var value interface{} = n.Value
+	if n.Value == "" {
+		value = n.Options
+	}
+
+	return json.Marshal(struct {
+		Type         string        `json:"type"`
+		Value        interface{}   `json:"value"`
+		Digest       bool          `json:"digest"`
+		QuietWindows []QuietWindow `json:"quiet_windows,omitempty"`
+	}{n.Type, value, n.Digest, n.QuietWindows})
+}
+
+// DiffKind describes how an item changed
+type DiffKind string
+
+const (
+	// DiffAdded is used for items not known before
+	DiffAdded DiffKind = "added"
+	// DiffChanged is used for items with a changed value
+	DiffChanged DiffKind = "changed"
+	// DiffRemoved is used for records no longer part of their collection
+	DiffRemoved DiffKind = "removed"
+)
+
 // Diff defines the diff between two watch states over time
 type Diff struct {
 	Item, OldValue, NewValue string
+	Kind                     DiffKind
+	// Type is the declared type of the item
+	Type string
+	// Change describes the difference of numbers and currency amounts, e.g. "+2.5 (+10.0%)"
+	Change string
+	// Collection is the collection of a record, Fields holds the changes of a modified record
+	Collection string
+	Fields     []FieldChange
+}
+
+// FieldChange is a changed field of a record
+type FieldChange struct {
+	Field, OldValue, NewValue string
+}
+
+// Run holds metadata of a job execution
+type Run struct {
+	// ID is the UUID of the puppet-master job
+	ID        string
+	StartedAt time.Time
+	Duration  time.Duration
+	// Warnings are the problems of flagged values
+	Warnings []string
+}
+
+// Notification is passed to notifiers and is the data available in notification templates
+type Notification struct {
+	Job *Job
+	// Target is the plain notify value, Options holds the structured one
+	Target  string
+	Options NotifyOptions
+	Diff    []Diff
+	// Values holds the current value of all items
+	Values map[string]string
+	Run    Run
+	// Templates are the resolved template files of the notifier
+	Templates Templates
+	// Initial is set on the summary of the first run of a job, which has no diff
+	Initial bool
+}
+
+// FailureKind tells why a run failed
+type FailureKind string
+
+const (
+	// FailureTimeout is a run exceeding its timeout
+	FailureTimeout FailureKind = "timeout"
+	// FailureExecution is a puppet-master job that could not be executed or failed
+	FailureExecution FailureKind = "execution"
+	// FailureInvalid is a run with values not matching the types or patterns of their items
+	FailureInvalid FailureKind = "invalid"
+	// FailureSuspect is a run with results suspected to be broken by the guards of the job
+	FailureSuspect FailureKind = "suspect"
+	// FailureError is any other error, e.g. of storage or notifications
+	FailureError FailureKind = "error"
+)
+
+// Failure is passed to notifiers supporting failure notifications and is the data available in their templates
+type Failure struct {
+	Job *Job
+	// Target is the plain notify value, Options holds the structured one
+	Target  string
+	Options NotifyOptions
+	Kind    FailureKind
+	Error   string
+	Run     Run
+}
+
+// DigestRun is a run with changes queued for a digest
+type DigestRun struct {
+	Run    Run               `json:"run"`
+	Diff   []Diff            `json:"diff"`
+	Values map[string]string `json:"values"`
+}
+
+// DigestJob holds the queued runs of a job
+type DigestJob struct {
+	Name string
+	Runs []DigestRun
+}
+
+// Digest is passed to notifiers supporting digests and is the data available in digest templates
+type Digest struct {
+	Target  string
+	Options NotifyOptions
+	Jobs    []DigestJob
+	// Templates are the resolved global template files of the notifier
+	Templates Templates
 }
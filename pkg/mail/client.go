@@ -1,20 +1,131 @@
 package mail
 
-import "gopkg.in/gomail.v2"
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
-// Client handles sending of mails
+	"github.com/Sirupsen/logrus"
+	"gopkg.in/gomail.v2"
+)
+
+var errTimeout = errors.New("SMTP server timed out")
+
+// Client handles sending of mails, keeping the SMTP connection open between sends
 type Client struct {
-	dialer *gomail.Dialer
+	mu      sync.Mutex
+	logger  *logrus.Entry
+	dialer  Dialer
+	timeout time.Duration
+	conn    gomail.SendCloser
 }
 
-// New returns a new Client instance
-func New(dialer *gomail.Dialer) *Client {
+// New returns a new Client instance. Dialing, sending and closing fail after the given timeout, if set.
+func New(logger *logrus.Entry, dialer Dialer, timeout time.Duration) *Client {
 	return &Client{
-		dialer: dialer,
+		logger:  logger,
+		dialer:  dialer,
+		timeout: timeout,
+	}
+}
+
+// Send sends the given mails over the open SMTP connection, dialing a new one if necessary
+func (c *Client) Send(msgs ...*gomail.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, msg := range msgs {
+		if err := c.send(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// send sends a single mail. Connections closed by the server are redialed by gomail before MAIL FROM.
+// Other failures aren't retried, as the mail may have been sent already, but drop the connection.
+func (c *Client) send(msg *gomail.Message) error {
+	if c.conn == nil {
+		var conn gomail.SendCloser
+		err := c.withTimeout(func() error {
+			var err error
+			conn, err = c.dialer.Dial()
+			return err
+		}, func() {
+			if conn != nil {
+				c.close(conn)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("failed to connect to SMTP server: %v", err)
+		}
+		c.conn = conn
+	}
+
+	conn := c.conn
+	err := c.withTimeout(func() error {
+		return gomail.Send(conn, msg)
+	}, func() {
+		c.close(conn)
+	})
+	if err != nil {
+		// connections timing out are closed once abandoned
+		c.conn = nil
+		if err != errTimeout {
+			go c.close(conn)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Close closes the open SMTP connection
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
 	}
+
+	conn := c.conn
+	c.conn = nil
+	return c.withTimeout(conn.Close, func() {})
 }
 
-// Send sends a given mail using SMTP
-func (c *Client) Send(msg *gomail.Message) error {
-	return c.dialer.DialAndSend(msg)
+// withTimeout runs fn for up to c.timeout. If it times out, fn keeps running in the background
+// and abandoned is called once it returns, e.g. to close its connection.
+func (c *Client) withTimeout(fn func() error, abandoned func()) error {
+	if c.timeout <= 0 {
+		return fn()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		go func() {
+			<-done
+			abandoned()
+		}()
+		return errTimeout
+	}
+}
+
+// close ends an SMTP session which is not used anymore, logging failures
+func (c *Client) close(conn gomail.SendCloser) {
+	if err := conn.Close(); err != nil {
+		c.logger.Warnf("failed to close SMTP connection: %v", err)
+	}
 }
@@ -0,0 +1,97 @@
+package mail
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"gopkg.in/gomail.v2"
+)
+
+// fakeSender fails the given number of sends
+type fakeSender struct {
+	fail  int
+	delay time.Duration
+}
+
+func (s *fakeSender) Send(from string, to []string, msg io.WriterTo) error {
+	time.Sleep(s.delay)
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("552 message rejected")
+	}
+
+	return nil
+}
+
+func (s *fakeSender) Close() error {
+	return nil
+}
+
+type fakeDialer struct {
+	senders []*fakeSender
+	dials   int
+}
+
+func (d *fakeDialer) Dial() (gomail.SendCloser, error) {
+	s := d.senders[d.dials]
+	d.dials++
+	return s, nil
+}
+
+func TestClientSend(t *testing.T) {
+	tests := []struct {
+		name      string
+		senders   []*fakeSender
+		timeout   time.Duration
+		wantErrs  []bool
+		wantDials int
+	}{
+		{
+			name:      "reuses connection",
+			senders:   []*fakeSender{{}},
+			wantErrs:  []bool{false, false},
+			wantDials: 1,
+		},
+		{
+			name:      "failed send is not retried",
+			senders:   []*fakeSender{{fail: 1}, {}},
+			wantErrs:  []bool{true, false},
+			wantDials: 2,
+		},
+		{
+			name:      "timed out send",
+			senders:   []*fakeSender{{delay: 50 * time.Millisecond}, {}},
+			timeout:   10 * time.Millisecond,
+			wantErrs:  []bool{true, false},
+			wantDials: 2,
+		},
+	}
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDialer{senders: tt.senders}
+			c := New(logrus.NewEntry(logger), d, tt.timeout)
+
+			for i, wantErr := range tt.wantErrs {
+				msg := gomail.NewMessage()
+				msg.SetHeader("From", "watcher@example.com")
+				msg.SetHeader("To", "user@example.com")
+
+				if err := c.Send(msg); (err != nil) != wantErr {
+					t.Errorf("send %d: got error %v, want error %v", i, err, wantErr)
+				}
+			}
+
+			if d.dials != tt.wantDials {
+				t.Errorf("got %d dials, want %d", d.dials, tt.wantDials)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package mail
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+const (
+	// TLSModeStartTLS upgrades the connection using STARTTLS, if supported by the server
+	TLSModeStartTLS = "starttls"
+	// TLSModeImplicit connects using TLS right away, usually on port 465
+	TLSModeImplicit = "implicit"
+	// TLSModeNone never encrypts the connection, even if the server supports STARTTLS
+	TLSModeNone = "none"
+
+	// dialTimeout is the timeout of connecting, as used by gomail
+	dialTimeout = 10 * time.Second
+)
+
+// Dialer opens authenticated connections to an SMTP server
+type Dialer interface {
+	Dial() (gomail.SendCloser, error)
+}
+
+// NewDialer returns a dialer of the SMTP server. An empty tlsMode uses implicit TLS on port 465 and STARTTLS otherwise.
+func NewDialer(host string, port int, username, password, tlsMode string) (Dialer, error) {
+	d := gomail.NewDialer(host, port, username, password)
+
+	switch tlsMode {
+	case "":
+	case TLSModeImplicit:
+		d.SSL = true
+	case TLSModeStartTLS:
+		d.SSL = false
+	case TLSModeNone:
+		// gomail always upgrades connections using STARTTLS if the server supports it
+		return &plainDialer{host: host, port: port, username: username, password: password}, nil
+	default:
+		return nil, fmt.Errorf("unknown SMTP TLS mode %q", tlsMode)
+	}
+
+	d.TLSConfig = &tls.Config{ServerName: host}
+	return d, nil
+}
+
+// plainDialer opens unencrypted connections to an SMTP server
+type plainDialer struct {
+	host     string
+	port     int
+	username string
+	password string
+}
+
+// Dial connects and authenticates to the SMTP server without STARTTLS
+func (d *plainDialer) Dial() (gomail.SendCloser, error) {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", d.host, d.port), dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := smtp.NewClient(conn, d.host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if d.username != "" {
+		if err := c.Auth(d.auth(c)); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+
+	return &plainSender{client: c, dialer: d}, nil
+}
+
+// auth returns the authentication supported by the server. Unlike smtp.PlainAuth, it sends plain
+// credentials over the unencrypted connection, as configured.
+func (d *plainDialer) auth(c *smtp.Client) smtp.Auth {
+	if _, auths := c.Extension("AUTH"); strings.Contains(auths, "CRAM-MD5") {
+		return smtp.CRAMMD5Auth(d.username, d.password)
+	}
+
+	return &unencryptedPlainAuth{username: d.username, password: d.password}
+}
+
+// unencryptedPlainAuth implements the PLAIN authentication mechanism without requiring TLS
+type unencryptedPlainAuth struct {
+	username string
+	password string
+}
+
+func (a *unencryptedPlainAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return "PLAIN", []byte("\x00" + a.username + "\x00" + a.password), nil
+}
+
+func (a *unencryptedPlainAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, fmt.Errorf("unexpected server challenge: %s", fromServer)
+	}
+
+	return nil, nil
+}
+
+// plainSender sends mails over an unencrypted SMTP connection
+type plainSender struct {
+	client *smtp.Client
+	dialer *plainDialer
+}
+
+// Send sends a mail, redialing once if the server closed the connection before MAIL FROM like gomail does
+func (s *plainSender) Send(from string, to []string, msg io.WriterTo) error {
+	if err := s.client.Mail(from); err != nil {
+		if err != io.EOF {
+			return err
+		}
+
+		sc, err := s.dialer.Dial()
+		if err != nil {
+			return err
+		}
+		_ = s.client.Close()
+		*s = *sc.(*plainSender)
+
+		if err := s.client.Mail(from); err != nil {
+			return err
+		}
+	}
+
+	for _, addr := range to {
+		if err := s.client.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := s.client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := msg.WriteTo(w); err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// Close ends the SMTP session
+func (s *plainSender) Close() error {
+	return s.client.Quit()
+}
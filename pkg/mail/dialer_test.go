@@ -0,0 +1,147 @@
+package mail
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/gomail.v2"
+)
+
+func TestNewDialer(t *testing.T) {
+	tests := []struct {
+		name    string
+		port    int
+		tlsMode string
+		wantSSL bool
+		wantErr bool
+	}{
+		{name: "default on 587", port: 587},
+		{name: "default on 465", port: 465, wantSSL: true},
+		{name: "starttls", port: 465, tlsMode: TLSModeStartTLS},
+		{name: "implicit", port: 587, tlsMode: TLSModeImplicit, wantSSL: true},
+		{name: "none", port: 25, tlsMode: TLSModeNone},
+		{name: "unknown", port: 25, tlsMode: "tls", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDialer("smtp.example.com", tt.port, "user", "pass", tt.tlsMode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			switch d := d.(type) {
+			case *plainDialer:
+				if tt.tlsMode != TLSModeNone {
+					t.Errorf("got unencrypted dialer for mode %q", tt.tlsMode)
+				}
+			case *gomail.Dialer:
+				if tt.tlsMode == TLSModeNone {
+					t.Fatal("got gomail dialer upgrading connections for mode none")
+				}
+				if d.SSL != tt.wantSSL {
+					t.Errorf("got SSL %v, want %v", d.SSL, tt.wantSSL)
+				}
+			default:
+				t.Fatalf("got unexpected dialer %T", d)
+			}
+		})
+	}
+}
+
+// serveSMTP answers a single SMTP session advertising STARTTLS and returns the commands received
+func serveSMTP(t *testing.T) (int, <-chan []string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commands := make(chan []string, 1)
+	go func() {
+		defer l.Close()
+
+		var received []string
+		defer func() { commands <- received }()
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake")
+
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			cmd := strings.ToUpper(strings.Fields(line)[0])
+			received = append(received, cmd)
+			switch cmd {
+			case "EHLO":
+				reply("250-fake\r\n250-STARTTLS\r\n250 AUTH PLAIN")
+			case "STARTTLS":
+				reply("454 not available")
+			case "AUTH":
+				reply("235 authenticated")
+			case "DATA":
+				reply("354 go ahead")
+				for line != ".\r\n" {
+					if line, err = r.ReadString('\n'); err != nil {
+						return
+					}
+				}
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return l.Addr().(*net.TCPAddr).Port, commands
+}
+
+func TestPlainDialerNeverUpgrades(t *testing.T) {
+	port, commands := serveSMTP(t)
+
+	d, err := NewDialer("127.0.0.1", port, "user", "pass", TLSModeNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := d.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", "watcher@example.com")
+	msg.SetHeader("To", "user@example.com")
+	msg.SetBody("text/plain", "hi")
+	if err := gomail.Send(s, msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"EHLO", "AUTH", "MAIL", "RCPT", "DATA", "QUIT"}
+	if got := <-commands; !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %v, want %v", got, want)
+	}
+}
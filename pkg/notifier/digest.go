@@ -0,0 +1,116 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+var defaultDigestSubjectTemplate = `Digest of {{ len .Jobs }} watched job{{ if gt (len .Jobs) 1 }}s{{ end }}`
+
+var defaultDigestBodyTemplate = `
+<html>
+<head>
+</head>
+<body style="font-family: Arial">
+Hi.<br />
+<br />
+You are receiving this digest because you registered to get updates on the following jobs.<br />
+<br />
+
+{{ range .Jobs }}
+	<h3>{{ .Name }}</h3>
+	{{ range .Runs }}
+	<b>Changes of run at {{ formatTime "2006-01-02 15:04:05 MST" .Run.StartedAt }}:</b>
+	<table border="1" cellpadding="0" cellspacing="0" style="border: 1px solid black;">
+		<tr>
+			<th>Name/Item</th>
+			<th>Old/new value</th>
+		</tr>
+	{{ range .Diff }}
+		<tr>
+			<td valign="top">{{ .Item }}{{ if or .Collection (eq .Kind "removed") }} ({{ .Kind }}){{ end }}</td>
+			<td valign="top">
+			{{ if .Fields }}
+				{{ range .Fields }}{{ .Field }}: {{ .OldValue }} &rarr; {{ .NewValue }}<br />{{ end }}
+			{{ else if isLong .OldValue .NewValue }}
+				{{ htmlDiff .OldValue .NewValue }}
+			{{ else }}
+				Old:  {{ .OldValue }}<br />
+				New: {{ .NewValue }}
+			{{ end }}
+			</td>
+		</tr>
+	{{ end }}
+	</table>
+	<br />
+	{{ end }}
+{{ end }}
+<br />
+Yours, the website-content-watcher.<br />
+A <i>Scalify</i> Service.
+</body>
+</html>
+`
+
+var defaultDigestTextTemplate = `Hi.
+
+You are receiving this digest because you registered to get updates on the following jobs.
+{{ range .Jobs }}
+== {{ .Name }} ==
+{{ range .Runs }}
+Changes of run at {{ formatTime "2006-01-02 15:04:05 MST" .Run.StartedAt }}:
+{{ range .Diff }}
+* {{ .Item }}{{ if or .Collection (eq .Kind "removed") }} ({{ .Kind }}){{ end }}
+{{- if .Fields }}
+{{- range .Fields }}
+  {{ .Field }}: {{ .OldValue }} -> {{ .NewValue }}
+{{- end }}
+{{ else if isLong .OldValue .NewValue }}
+
+{{ unifiedDiff .OldValue .NewValue }}
+{{- else }}
+  Old: {{ .OldValue }}
+  New: {{ .NewValue }}
+{{ end }}
+{{- end }}
+{{- end }}
+{{- end }}
+Yours, the website-content-watcher.
+A Scalify Service.
+`
+
+// NotifyDigest sends a single email holding all queued changes of the digest
+func (m *Mail) NotifyDigest(d api.Digest) error {
+	subject, text, body, err := m.renderDigest(d)
+	if err != nil {
+		return err
+	}
+
+	if err := m.client.Send(m.newMessage(d.Target, d.Options, subject, text, body)); err != nil {
+		return fmt.Errorf("failed to send mail: %v", err)
+	}
+
+	return nil
+}
+
+// renderDigest returns the subject, the plain text and the HTML body of a digest mail
+func (m *Mail) renderDigest(d api.Digest) (string, string, string, error) {
+	subject, err := m.templates.render("digest subject", d.Templates.DigestSubject, defaultDigestSubjectTemplate, d)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	text, err := m.templates.render("digest text", d.Templates.DigestText, defaultDigestTextTemplate, d)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	body, err := m.templates.renderHTML("digest body", d.Templates.DigestBody, defaultDigestBodyTemplate, d)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return strings.TrimSpace(subject), text, body, nil
+}
@@ -0,0 +1,65 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+var defaultFailureSubjectTemplate = `Watch job {{ .Job.Name }} failed ({{ .Kind }})`
+
+var defaultFailureBodyTemplate = `
+<html>
+<head>
+</head>
+<body style="font-family: Arial">
+Hi.<br />
+<br />
+The run of the watch job <b>{{ .Job.Name }}</b> started at {{ formatTime "2006-01-02 15:04:05 MST" .Run.StartedAt }} failed
+after {{ .Run.Duration }}.<br />
+<br />
+<b>Failure:</b> {{ .Kind }}<br />
+<b>Error:</b> {{ .Error }}<br />
+<br />
+Yours, the website-content-watcher.<br />
+A <i>Scalify</i> Service.
+</body>
+</html>
+`
+
+var defaultFailureTextTemplate = `Hi.
+
+The run of the watch job {{ .Job.Name }} started at {{ formatTime "2006-01-02 15:04:05 MST" .Run.StartedAt }} failed
+after {{ .Run.Duration }}.
+
+Failure: {{ .Kind }}
+Error: {{ .Error }}
+
+Yours, the website-content-watcher.
+A Scalify Service.
+`
+
+// NotifyFailure sends an email about a failed run
+func (m *Mail) NotifyFailure(f api.Failure) error {
+	subject, err := m.templates.render("failure subject", "", defaultFailureSubjectTemplate, f)
+	if err != nil {
+		return err
+	}
+
+	text, err := m.templates.render("failure text", "", defaultFailureTextTemplate, f)
+	if err != nil {
+		return err
+	}
+
+	body, err := m.templates.renderHTML("failure body", "", defaultFailureBodyTemplate, f)
+	if err != nil {
+		return err
+	}
+
+	if err := m.client.Send(m.newMessage(f.Target, f.Options, strings.TrimSpace(subject), text, body)); err != nil {
+		return fmt.Errorf("failed to send mail: %v", err)
+	}
+
+	return nil
+}
@@ -1,24 +1,30 @@
 package notifier
 
 import (
-	"bytes"
 	"fmt"
-	"text/template"
+	"strings"
 
 	"github.com/Scalify/website-content-watcher/pkg/api"
 	"gopkg.in/gomail.v2"
 )
 
-var mailTemplate = `
+var defaultSubjectTemplate = `{{ if .Initial }}Now watching job {{ .Job.Name }}{{ else }}Update on watched job {{ .Job.Name }}{{ end }}`
+
+var defaultBodyTemplate = `
 <html>
 <head>
 </head>
 <body style="font-family: Arial">
 Hi.<br />
 <br />
-You are receiving this mail because you registered to get updates on job <i>{{ .JobName }}</i>.<br />
+You are receiving this mail because you registered to get updates on job <i>{{ .Job.Name }}</i>.<br />
 <br />
 
+{{ if .Initial }}
+	Now watching {{ len .Values }} items. You will be notified about changes from now on.<br />
+	<br />
+{{ end }}
+
 {{ if .Diff }}
 	<b>The following items changed since last execution:</b>
 	<table border="1" cellpadding="0" cellspacing="0" style="border: 1px solid black;">
@@ -28,16 +34,32 @@ You are receiving this mail because you registered to get updates on job <i>{{ .
 		</tr>
 	{{ range .Diff }}
 		<tr>
-			<td valign="top">{{ .Item }}</td>
+			<td valign="top">{{ .Item }}{{ if or .Collection (eq .Kind "removed") }} ({{ .Kind }}){{ end }}</td>
 			<td valign="top">
+			{{ if .Fields }}
+				{{ range .Fields }}{{ .Field }}: {{ .OldValue }} &rarr; {{ .NewValue }}<br />{{ end }}
+			{{ else if isLong .OldValue .NewValue }}
+				{{ htmlDiff .OldValue .NewValue }}
+			{{ else }}
 				Old:  {{ .OldValue }}<br />
 				New: {{ .NewValue }}
+			{{ end }}
+			{{ if .Change }}<br />Change: {{ .Change }}{{ end }}
 			</td>
 		</tr>
 	{{ end }}
 	</table>
 	<br />
-	<br/>
+	<br />
+{{ end }}
+
+{{ if .Run.Warnings }}
+	<b>Some values are invalid:</b>
+	<ul>
+	{{ range .Run.Warnings }}
+		<li>{{ . }}</li>
+	{{ end }}
+	</ul>
 {{ end }}
 
 
@@ -47,16 +69,14 @@ Current status of all items:<br />
 		<th>Name/Item</th>
 		<th>Value</th>
 	</tr>
-{{ range $key, $value := .NewValues }}
+{{ range $key, $value := .Values }}
 	<tr>
 		<td valign="top">{{ $key }}</td>
 		<td valign="top">{{ $value }}</td>
 	</tr>
 {{ end }}
 </table>
-<br /
 <br />
-Have fun with that info. You're welcome.<br />
 <br />
 Yours, the website-content-watcher.<br />
 A <i>Scalify</i> Service.
@@ -64,22 +84,66 @@ A <i>Scalify</i> Service.
 </html>
 `
 
+var defaultTextTemplate = `Hi.
+
+You are receiving this mail because you registered to get updates on job "{{ .Job.Name }}".
+{{- if .Initial }}
+
+Now watching {{ len .Values }} items. You will be notified about changes from now on.
+{{- end }}
+{{ if .Diff }}
+The following items changed since last execution:
+{{ range .Diff }}
+* {{ .Item }}{{ if or .Collection (eq .Kind "removed") }} ({{ .Kind }}){{ end }}
+{{- if .Fields }}
+{{- range .Fields }}
+  {{ .Field }}: {{ .OldValue }} -> {{ .NewValue }}
+{{- end }}
+{{ else if isLong .OldValue .NewValue }}
+
+{{ unifiedDiff .OldValue .NewValue }}
+{{- else }}
+  Old: {{ .OldValue }}
+  New: {{ .NewValue }}
+{{- if .Change }}
+  Change: {{ .Change }}
+{{- end }}
+{{ end }}
+{{- end }}
+{{- end }}
+{{- if .Run.Warnings }}
+Some values are invalid:
+{{ range .Run.Warnings }}
+* {{ . }}
+{{- end }}
+{{ end }}
+Current status of all items:
+{{ range $key, $value := .Values }}
+* {{ $key }}: {{ $value }}
+{{- end }}
+
+Yours, the website-content-watcher.
+A Scalify Service.
+`
+
 // MailClient is an SMTP client for sending mails
 type MailClient interface {
-	Send(msg *gomail.Message) error
+	Send(msgs ...*gomail.Message) error
 }
 
 // Mail is a notifier sending mails
 type Mail struct {
-	sender string
-	client MailClient
+	sender    string
+	client    MailClient
+	templates *templateCache
 }
 
 // NewMail returns a new Mail instance
 func NewMail(sender string, client MailClient) *Mail {
 	return &Mail{
-		sender: sender,
-		client: client,
+		sender:    sender,
+		client:    client,
+		templates: newTemplateCache(),
 	}
 }
 
@@ -88,45 +152,108 @@ func (m *Mail) Key() string {
 	return "mail"
 }
 
-// Notify sends an email to given target
-func (m *Mail) Notify(jobName, target string, diff []api.Diff, newValues map[string]string) error {
-	msg := gomail.NewMessage()
-	msg.SetHeader("From", m.sender)
-	msg.SetHeader("To", target)
-	msg.SetHeader("Subject", fmt.Sprintf("Update on watched job %s", jobName))
+// CheckTemplates validates the templates of a job by rendering them with sample data
+func (m *Mail) CheckTemplates(job *api.Job, templates api.Templates) error {
+	if _, _, _, err := m.render(sampleNotification(job, templates)); err != nil {
+		return err
+	}
 
-	body, err := m.renderTemplate(jobName, diff, newValues)
-	if err != nil {
-		return fmt.Errorf("failed to render body: %v", err)
+	_, _, _, err := m.renderDigest(sampleDigest(job, templates))
+	return err
+}
+
+// CheckEntry validates the recipients of a notify entry
+func (m *Mail) CheckEntry(entry api.NotifyEntry) error {
+	if len(recipients(entry.Value, entry.Options)) == 0 {
+		return fmt.Errorf("mail notify entry has no recipient")
 	}
 
-	msg.SetBody("text/html", body)
+	return nil
+}
+
+// Notify sends an email with a plain text and an HTML part to given target
+func (m *Mail) Notify(n api.Notification) error {
+	return m.NotifyAll([]api.Notification{n})
+}
+
+// NotifyAll sends the emails of all given notifications over a single connection
+func (m *Mail) NotifyAll(notifications []api.Notification) error {
+	msgs := make([]*gomail.Message, 0, len(notifications))
+	for _, n := range notifications {
+		msg, err := m.message(n)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+	}
 
-	if err := m.client.Send(msg); err != nil {
+	if err := m.client.Send(msgs...); err != nil {
 		return fmt.Errorf("failed to send mail: %v", err)
 	}
 
 	return nil
 }
 
-func (m *Mail) renderTemplate(jobName string, diff []api.Diff, newValues map[string]string) (string, error) {
-	t := template.New("mail")
-	if _, err := t.Parse(mailTemplate); err != nil {
-		return "", fmt.Errorf("failed to parse email template: %v", err)
+func (m *Mail) message(n api.Notification) (*gomail.Message, error) {
+	subject, text, body, err := m.render(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.newMessage(n.Target, n.Options, subject, text, body), nil
+}
+
+// newMessage creates a multipart mail to the recipients of a plain or structured notify value
+func (m *Mail) newMessage(target string, options api.NotifyOptions, subject, text, body string) *gomail.Message {
+	if options.SubjectPrefix != "" {
+		subject = options.SubjectPrefix + " " + subject
+	}
+
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", m.sender)
+	msg.SetHeader("To", recipients(target, options)...)
+	if len(options.Cc) > 0 {
+		msg.SetHeader("Cc", options.Cc...)
+	}
+	if len(options.Bcc) > 0 {
+		msg.SetHeader("Bcc", options.Bcc...)
+	}
+	if options.ReplyTo != "" {
+		msg.SetHeader("Reply-To", options.ReplyTo)
+	}
+	msg.SetHeader("Subject", subject)
+	msg.SetBody("text/plain", text)
+	msg.AddAlternative("text/html", body)
+
+	return msg
+}
+
+// render returns the subject, the plain text and the HTML body of a mail
+func (m *Mail) render(n api.Notification) (string, string, string, error) {
+	subject, err := m.templates.render("subject", n.Templates.Subject, defaultSubjectTemplate, n)
+	if err != nil {
+		return "", "", "", err
 	}
 
-	buf := &bytes.Buffer{}
-	data := struct {
-		JobName string
-		Diff    []api.Diff
-		NewValues map[string]string
-	}{
-		JobName: jobName,
-		Diff:    diff,
-		NewValues: newValues,
+	text, err := m.templates.render("text", n.Templates.Text, defaultTextTemplate, n)
+	if err != nil {
+		return "", "", "", err
 	}
 
-	err := t.Execute(buf, data)
+	body, err := m.templates.renderHTML("body", n.Templates.Body, defaultBodyTemplate, n)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return strings.TrimSpace(subject), text, body, nil
+}
+
+// recipients returns the To addresses of a plain or structured notify value
+func recipients(target string, options api.NotifyOptions) []string {
+	var to []string
+	if target != "" {
+		to = append(to, target)
+	}
 
-	return buf.String(), err
+	return append(to, options.To...)
 }
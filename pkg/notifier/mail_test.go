@@ -0,0 +1,60 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestMailRenderEscapesValues(t *testing.T) {
+	long := strings.Repeat("word ", 20)
+
+	tests := []struct {
+		name     string
+		diff     api.Diff
+		want     []string
+		wantText string
+	}{
+		{
+			name:     "short value",
+			diff:     api.Diff{Item: "title", OldValue: "<b>old</b>", NewValue: "<b>new</b>", Kind: api.DiffChanged},
+			want:     []string{"&lt;b&gt;old&lt;/b&gt;", "&lt;b&gt;new&lt;/b&gt;"},
+			wantText: "New: <b>new</b>",
+		},
+		{
+			name:     "long value",
+			diff:     api.Diff{Item: "text", OldValue: long + "<b>old</b>", NewValue: long + "<b>new</b>", Kind: api.DiffChanged},
+			want:     []string{"<del", "&lt;b&gt;old&lt;/b&gt;", "<ins", "&lt;b&gt;new&lt;/b&gt;"},
+			wantText: "+" + long + "<b>new</b>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMail("watcher@example.com", nil)
+			n := api.Notification{
+				Job:    &api.Job{Name: "<i>job</i>"},
+				Diff:   []api.Diff{tt.diff},
+				Values: map[string]string{tt.diff.Item: tt.diff.NewValue},
+			}
+
+			_, text, body, err := m.render(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range append(tt.want, "&lt;i&gt;job&lt;/i&gt;") {
+				if !strings.Contains(body, want) {
+					t.Errorf("body doesn't contain %q:\n%s", want, body)
+				}
+			}
+			if strings.Contains(body, "<b>new") || strings.Contains(body, "<i>job") {
+				t.Errorf("body contains unescaped markup:\n%s", body)
+			}
+			if !strings.Contains(text, tt.wantText) {
+				t.Errorf("text doesn't contain %q:\n%s", tt.wantText, text)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+	"github.com/Scalify/website-content-watcher/pkg/text"
+	"github.com/Scalify/website-content-watcher/pkg/textdiff"
+)
+
+// templateFuncs are available in all notification templates
+var templateFuncs = template.FuncMap{
+	"isLong":      textdiff.IsLong,
+	"htmlDiff":    textdiff.HTML,
+	"unifiedDiff": textdiff.Unified,
+	"byKind":      byKind,
+	"truncate":    text.Truncate,
+	"formatTime":  formatTime,
+	"lower":       strings.ToLower,
+	"upper":       strings.ToUpper,
+}
+
+// htmlTemplateFuncs are available in HTML templates, which escape all values except the rendered diffs
+var htmlTemplateFuncs = htmlFuncs()
+
+// executable is a parsed text or HTML template
+type executable interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// templateCache parses templates once and keeps them by file name
+type templateCache struct {
+	mu        sync.Mutex
+	templates map[string]executable
+}
+
+func newTemplateCache() *templateCache {
+	return &templateCache{
+		templates: make(map[string]executable),
+	}
+}
+
+// get returns the template of the given file, or the named default template if file is empty.
+// HTML templates escape the values they insert.
+// nolint: gosec
+func (c *templateCache) get(name, file, fallback string, html bool) (executable, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := file
+	if key == "" {
+		key = "default " + name
+	}
+	if html {
+		key = "html " + key
+	}
+
+	if t, ok := c.templates[key]; ok {
+		return t, nil
+	}
+
+	text := fallback
+	if file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s template file %q: %v", name, file, err)
+		}
+		text = string(b)
+	}
+
+	var t executable
+	var err error
+	if html {
+		t, err = htmltemplate.New(key).Funcs(htmlTemplateFuncs).Parse(text)
+	} else {
+		t, err = template.New(key).Funcs(templateFuncs).Parse(text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s template: %v", name, err)
+	}
+
+	c.templates[key] = t
+	return t, nil
+}
+
+// render executes the named plain text template of the given file with the given data
+func (c *templateCache) render(name, file, fallback string, data interface{}) (string, error) {
+	return c.execute(name, file, fallback, false, data)
+}
+
+// renderHTML executes the named HTML template of the given file with the given data
+func (c *templateCache) renderHTML(name, file, fallback string, data interface{}) (string, error) {
+	return c.execute(name, file, fallback, true, data)
+}
+
+func (c *templateCache) execute(name, file, fallback string, html bool, data interface{}) (string, error) {
+	t, err := c.get(name, file, fallback, html)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %v", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// sampleNotification is used to validate templates on startup
+func sampleNotification(job *api.Job, templates api.Templates) api.Notification {
+	return api.Notification{
+		Job:    job,
+		Target: "sample",
+		Diff: []api.Diff{
+			{Item: "added item", NewValue: "new", Kind: api.DiffAdded},
+			{Item: "changed item", OldValue: "old", NewValue: "new", Kind: api.DiffChanged},
+		},
+		Values: map[string]string{
+			"added item":   "new",
+			"changed item": "new",
+		},
+		Run: api.Run{
+			ID:        "sample",
+			StartedAt: time.Now(),
+		},
+		Templates: templates,
+	}
+}
+
+// sampleDigest is used to validate digest templates on startup
+func sampleDigest(job *api.Job, templates api.Templates) api.Digest {
+	n := sampleNotification(job, templates)
+
+	return api.Digest{
+		Target: n.Target,
+		Jobs: []api.DigestJob{
+			{
+				Name: job.Name,
+				Runs: []api.DigestRun{{Run: n.Run, Diff: n.Diff, Values: n.Values}},
+			},
+		},
+		Templates: templates,
+	}
+}
+
+// byKind returns the diff entries of the given kind, e.g. "added"
+func byKind(kind string, diff []api.Diff) []api.Diff {
+	res := make([]api.Diff, 0, len(diff))
+	for _, d := range diff {
+		if string(d.Kind) == kind {
+			res = append(res, d)
+		}
+	}
+
+	return res
+}
+
+// htmlFuncs returns the template functions for HTML templates. The diff is escaped by textdiff.HTML already.
+func htmlFuncs() htmltemplate.FuncMap {
+	funcs := htmltemplate.FuncMap{}
+	for name, f := range templateFuncs {
+		funcs[name] = f
+	}
+
+	// nolint: gosec
+	funcs["htmlDiff"] = func(oldValue, newValue string) htmltemplate.HTML {
+		return htmltemplate.HTML(textdiff.HTML(oldValue, newValue))
+	}
+
+	return funcs
+}
+
+// formatTime formats a time using a Go time layout
+func formatTime(layout string, t time.Time) string {
+	return t.Format(layout)
+}
@@ -0,0 +1,298 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+)
+
+// bufferedWrite is a write kept in memory until the store is available again
+type bufferedWrite struct {
+	value   string
+	deleted bool
+	seq     uint64
+}
+
+// bufferedMember is a sorted set member kept in memory until the store is available again
+type bufferedMember struct {
+	score float64
+	seq   uint64
+}
+
+// Buffered keeps the writes to a storage in memory while it is unavailable and flushes them once it is back.
+// Reads of buffered keys are served from memory, other reads fail with ErrUnavailable during an outage.
+// No lock is held while talking to the store, so a hanging store never blocks reading the buffer or the health.
+type Buffered struct {
+	backend backend
+	maxKeys int
+
+	mu      sync.Mutex
+	writes  map[string]bufferedWrite
+	members map[string]map[string]bufferedMember
+	seq     uint64
+
+	healthMu sync.Mutex
+	healthy  bool
+	lastErr  error
+}
+
+// NewBuffered returns a new Buffered storage holding up to maxKeys buffered writes. It starts unhealthy
+// until the first successful Flush.
+func NewBuffered(backend backend, maxKeys int) *Buffered {
+	return &Buffered{
+		backend: backend,
+		maxKeys: maxKeys,
+		writes:  make(map[string]bufferedWrite),
+		members: make(map[string]map[string]bufferedMember),
+		lastErr: ErrUnavailable,
+	}
+}
+
+// Get fetches a value from the buffer or the store
+func (b *Buffered) Get(key string) (string, error) {
+	b.mu.Lock()
+	write, ok := b.writes[key]
+	b.mu.Unlock()
+
+	if ok {
+		if write.deleted {
+			return "", ErrNotFound
+		}
+		return write.value, nil
+	}
+
+	if !b.Healthy() {
+		return "", ErrUnavailable
+	}
+
+	value, err := b.backend.Get(key)
+	if err != nil && err != ErrNotFound {
+		b.fail(err)
+	}
+
+	return value, err
+}
+
+// Set sets a key to a given value, buffering it if the store is unavailable
+func (b *Buffered) Set(key, value string) error {
+	return b.write(key, bufferedWrite{value: value})
+}
+
+// Del deletes a key, buffering the deletion if the store is unavailable
+func (b *Buffered) Del(key string) error {
+	return b.write(key, bufferedWrite{deleted: true})
+}
+
+func (b *Buffered) write(key string, write bufferedWrite) error {
+	if b.Healthy() {
+		var err error
+		if write.deleted {
+			err = b.backend.Del(key)
+		} else {
+			err = b.backend.Set(key, write.value)
+		}
+
+		if err == nil {
+			// an older buffered write of the key must not be read or flushed anymore
+			b.mu.Lock()
+			delete(b.writes, key)
+			b.mu.Unlock()
+			return nil
+		}
+		b.fail(err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.writes[key]; !ok && b.size() >= b.maxKeys {
+		return fmt.Errorf("failed to buffer key %q: buffer of %d keys is full: %v", key, b.maxKeys, b.LastError())
+	}
+
+	b.seq++
+	write.seq = b.seq
+	b.writes[key] = write
+	return nil
+}
+
+// AddMember adds a member to a sorted set, buffering it if the store is unavailable
+func (b *Buffered) AddMember(key, member string, score float64) error {
+	if b.Healthy() {
+		err := b.backend.AddMember(key, member, score)
+		if err == nil {
+			b.mu.Lock()
+			b.removeMember(key, member, 0)
+			b.mu.Unlock()
+			return nil
+		}
+		b.fail(err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.members[key][member]; !ok && b.size() >= b.maxKeys {
+		return fmt.Errorf("failed to buffer member %q of %q: buffer of %d keys is full: %v", member, key, b.maxKeys, b.LastError())
+	}
+
+	if b.members[key] == nil {
+		b.members[key] = make(map[string]bufferedMember)
+	}
+	b.seq++
+	b.members[key][member] = bufferedMember{score: score, seq: b.seq}
+	return nil
+}
+
+// Members returns all members of a sorted set. It isn't buffered.
+func (b *Buffered) Members(key string) (map[string]float64, error) {
+	if !b.Healthy() {
+		return nil, ErrUnavailable
+	}
+
+	return b.backend.Members(key)
+}
+
+// RemoveMember removes a member from a sorted set. It isn't buffered.
+func (b *Buffered) RemoveMember(key, member string) error {
+	if !b.Healthy() {
+		return ErrUnavailable
+	}
+
+	return b.backend.RemoveMember(key, member)
+}
+
+// Keys returns all keys matching a glob pattern. It isn't buffered.
+func (b *Buffered) Keys(pattern string) ([]string, error) {
+	if !b.Healthy() {
+		return nil, ErrUnavailable
+	}
+
+	return b.backend.Keys(pattern)
+}
+
+// Flush checks the connection to the store and writes all buffered changes to it.
+// It returns whether the store became available again.
+func (b *Buffered) Flush() (bool, error) {
+	recovered := !b.Healthy()
+	if err := b.backend.Ping(); err != nil {
+		b.fail(err)
+		return false, err
+	}
+
+	writes, members := b.snapshot()
+
+	for key, write := range writes {
+		var err error
+		if write.deleted {
+			err = b.backend.Del(key)
+		} else {
+			err = b.backend.Set(key, write.value)
+		}
+		if err != nil {
+			b.fail(err)
+			return false, fmt.Errorf("failed to flush key %q: %v", key, err)
+		}
+
+		// writes buffered meanwhile are newer and flushed next time
+		b.mu.Lock()
+		if b.writes[key].seq == write.seq {
+			delete(b.writes, key)
+		}
+		b.mu.Unlock()
+	}
+
+	for key, set := range members {
+		for member, m := range set {
+			if err := b.backend.AddMember(key, member, m.score); err != nil {
+				b.fail(err)
+				return false, fmt.Errorf("failed to flush member %q of %q: %v", member, key, err)
+			}
+
+			b.mu.Lock()
+			b.removeMember(key, member, m.seq)
+			b.mu.Unlock()
+		}
+	}
+
+	b.healthMu.Lock()
+	b.healthy = true
+	b.lastErr = nil
+	b.healthMu.Unlock()
+
+	return recovered, nil
+}
+
+// Healthy returns whether the store is available
+func (b *Buffered) Healthy() bool {
+	b.healthMu.Lock()
+	defer b.healthMu.Unlock()
+
+	return b.healthy
+}
+
+// LastError returns the error the store became unavailable with, nil if it is available
+func (b *Buffered) LastError() error {
+	b.healthMu.Lock()
+	defer b.healthMu.Unlock()
+
+	return b.lastErr
+}
+
+// Status returns the number of buffered writes and the last error of the store, if it is unavailable
+func (b *Buffered) Status() (int, error) {
+	b.mu.Lock()
+	size := b.size()
+	b.mu.Unlock()
+
+	return size, b.LastError()
+}
+
+// snapshot copies the buffered writes and members to flush them without holding the lock
+func (b *Buffered) snapshot() (map[string]bufferedWrite, map[string]map[string]bufferedMember) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	writes := make(map[string]bufferedWrite, len(b.writes))
+	for key, write := range b.writes {
+		writes[key] = write
+	}
+
+	members := make(map[string]map[string]bufferedMember, len(b.members))
+	for key, set := range b.members {
+		members[key] = make(map[string]bufferedMember, len(set))
+		for member, m := range set {
+			members[key][member] = m
+		}
+	}
+
+	return writes, members
+}
+
+// removeMember drops a buffered member, unless it was buffered again after seq. A seq of 0 always drops it.
+func (b *Buffered) removeMember(key, member string, seq uint64) {
+	m, ok := b.members[key][member]
+	if !ok || (seq != 0 && m.seq != seq) {
+		return
+	}
+
+	delete(b.members[key], member)
+	if len(b.members[key]) == 0 {
+		delete(b.members, key)
+	}
+}
+
+func (b *Buffered) fail(err error) {
+	b.healthMu.Lock()
+	defer b.healthMu.Unlock()
+
+	b.healthy = false
+	b.lastErr = err
+}
+
+func (b *Buffered) size() int {
+	n := len(b.writes)
+	for _, set := range b.members {
+		n += len(set)
+	}
+
+	return n
+}
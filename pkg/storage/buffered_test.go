@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("connection refused")
+
+// fakeBackend is an in-memory backend which can be taken down
+type fakeBackend struct {
+	mu      sync.Mutex
+	values  map[string]string
+	members map[string]map[string]float64
+	down    bool
+	// onSet is called before a value is set, without holding the lock
+	onSet func(key, value string)
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		values:  make(map[string]string),
+		members: make(map[string]map[string]float64),
+	}
+}
+
+func (f *fakeBackend) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.down = down
+}
+
+func (f *fakeBackend) Get(key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down {
+		return "", errDown
+	}
+	value, ok := f.values[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (f *fakeBackend) Set(key, value string) error {
+	f.mu.Lock()
+	onSet := f.onSet
+	f.mu.Unlock()
+	if onSet != nil {
+		onSet(key, value)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down {
+		return errDown
+	}
+	f.values[key] = value
+	return nil
+}
+
+func (f *fakeBackend) Del(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down {
+		return errDown
+	}
+	delete(f.values, key)
+	return nil
+}
+
+func (f *fakeBackend) AddMember(key, member string, score float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down {
+		return errDown
+	}
+	if f.members[key] == nil {
+		f.members[key] = make(map[string]float64)
+	}
+	f.members[key][member] = score
+	return nil
+}
+
+func (f *fakeBackend) Members(key string) (map[string]float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down {
+		return nil, errDown
+	}
+	res := make(map[string]float64)
+	for member, score := range f.members[key] {
+		res[member] = score
+	}
+	return res, nil
+}
+
+func (f *fakeBackend) RemoveMember(key, member string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down {
+		return errDown
+	}
+	delete(f.members[key], member)
+	return nil
+}
+
+func (f *fakeBackend) Keys(pattern string) ([]string, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeBackend) Ping() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down {
+		return errDown
+	}
+	return nil
+}
+
+// newTestBuffered returns a healthy Buffered storage of a backend holding the given values
+func newTestBuffered(t *testing.T, maxKeys int, values map[string]string) (*Buffered, *fakeBackend) {
+	t.Helper()
+
+	f := newFakeBackend()
+	for key, value := range values {
+		f.values[key] = value
+	}
+
+	b := NewBuffered(f, maxKeys)
+	if _, err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	return b, f
+}
+
+func TestBufferedOutage(t *testing.T) {
+	tests := []struct {
+		name       string
+		write      func(b *Buffered) error
+		key        string
+		want       string
+		wantErr    error
+		wantStored map[string]string
+	}{
+		{
+			name:       "buffered value",
+			write:      func(b *Buffered) error { return b.Set("a", "new") },
+			key:        "a",
+			want:       "new",
+			wantStored: map[string]string{"a": "new", "b": "old"},
+		},
+		{
+			name:       "buffered deletion",
+			write:      func(b *Buffered) error { return b.Del("a") },
+			key:        "a",
+			wantErr:    ErrNotFound,
+			wantStored: map[string]string{"b": "old"},
+		},
+		{
+			name:       "latest buffered write",
+			write:      func(b *Buffered) error { _ = b.Del("a"); return b.Set("a", "again") },
+			key:        "a",
+			want:       "again",
+			wantStored: map[string]string{"a": "again", "b": "old"},
+		},
+		{
+			name:       "not buffered key",
+			write:      func(b *Buffered) error { return b.Set("a", "new") },
+			key:        "b",
+			wantErr:    ErrUnavailable,
+			wantStored: map[string]string{"a": "new", "b": "old"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, f := newTestBuffered(t, 10, map[string]string{"a": "old", "b": "old"})
+			f.setDown(true)
+
+			if err := tt.write(b); err != nil {
+				t.Fatal(err)
+			}
+			if b.Healthy() {
+				t.Fatal("got healthy store after failed write")
+			}
+
+			got, err := b.Get(tt.key)
+			if err != tt.wantErr {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+
+			if _, err := b.Flush(); err != errDown {
+				t.Fatalf("got flush error %v during outage, want %v", err, errDown)
+			}
+
+			f.setDown(false)
+			recovered, err := b.Flush()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !recovered {
+				t.Error("got not recovered after outage")
+			}
+			if !reflect.DeepEqual(f.values, tt.wantStored) {
+				t.Errorf("got stored values %v, want %v", f.values, tt.wantStored)
+			}
+			if size, err := b.Status(); size != 0 || err != nil {
+				t.Errorf("got status %d, %v after flush", size, err)
+			}
+		})
+	}
+}
+
+func TestBufferedMembers(t *testing.T) {
+	b, f := newTestBuffered(t, 10, nil)
+	f.setDown(true)
+
+	if err := b.AddMember("set", "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Members("set"); err != ErrUnavailable {
+		t.Fatalf("got error %v reading members during outage, want %v", err, ErrUnavailable)
+	}
+
+	f.setDown(false)
+	if _, err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	members, err := b.Members("set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(members, map[string]float64{"a": 1}) {
+		t.Errorf("got members %v", members)
+	}
+}
+
+func TestBufferedFull(t *testing.T) {
+	b, f := newTestBuffered(t, 2, nil)
+	f.setDown(true)
+
+	for _, key := range []string{"a", "b", "a"} {
+		if err := b.Set(key, "value"); err != nil {
+			t.Fatalf("failed to buffer %q: %v", key, err)
+		}
+	}
+
+	if err := b.Set("c", "value"); err == nil {
+		t.Error("got no error buffering a new key into a full buffer")
+	}
+	if err := b.AddMember("set", "a", 1); err == nil {
+		t.Error("got no error buffering a member into a full buffer")
+	}
+
+	if size, err := b.Status(); size != 2 || err != errDown {
+		t.Errorf("got status %d, %v, want 2, %v", size, err, errDown)
+	}
+}
+
+func TestBufferedFlushKeepsNewerWrites(t *testing.T) {
+	b, f := newTestBuffered(t, 10, nil)
+	f.setDown(true)
+	if err := b.Set("a", "old"); err != nil {
+		t.Fatal(err)
+	}
+	f.setDown(false)
+
+	// the flush hangs writing the old value, while a newer one is buffered
+	flushing, release := make(chan struct{}), make(chan struct{})
+	f.onSet = func(key, value string) {
+		if value == "old" {
+			close(flushing)
+			<-release
+		}
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := b.Flush()
+		done <- err
+	}()
+	<-flushing
+
+	status := make(chan int)
+	go func() {
+		size, _ := b.Status()
+		status <- size
+	}()
+	select {
+	case size := <-status:
+		if size != 1 {
+			t.Errorf("got %d buffered writes during flush, want 1", size)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("status blocked by hanging flush")
+	}
+
+	if err := b.Set("a", "new"); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := b.Get("a"); err != nil || got != "new" {
+		t.Fatalf("got %q, %v after flush, want newer buffered value", got, err)
+	}
+
+	if _, err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.values["a"]; got != "new" {
+		t.Errorf("got stored value %q, want %q", got, "new")
+	}
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/go-redis/redis"
+)
+
+// RedisConfig configures the connection to a single redis server, a Sentinel setup or a Cluster
+type RedisConfig struct {
+	// URL is a redis:// or rediss:// URL of a single server. It replaces Host, Port, DB and the credentials.
+	URL  string
+	Host string
+	Port int
+	DB   int
+
+	// Username is the ACL user to authenticate as, Password alone authenticates the default user
+	Username string
+	Password string
+
+	TLS                   bool
+	TLSCAFile             string
+	TLSInsecureSkipVerify bool
+
+	// SentinelMaster is the name of the master monitored by the Sentinels at SentinelAddrs
+	SentinelMaster string
+	SentinelAddrs  []string
+
+	// ClusterAddrs are the seed nodes of a Cluster
+	ClusterAddrs []string
+}
+
+// NewRedisClient returns a client for the configured redis setup. It doesn't connect until the first command.
+func NewRedisClient(cfg RedisConfig) (redis.UniversalClient, error) {
+	if cfg.URL != "" {
+		if err := parseRedisURL(&cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	password, db, onConnect := cfg.auth()
+
+	switch {
+	case len(cfg.ClusterAddrs) > 0:
+		if cfg.DB != 0 {
+			return nil, fmt.Errorf("redis cluster only supports database 0")
+		}
+
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     cfg.ClusterAddrs,
+			Password:  password,
+			OnConnect: onConnect,
+			TLSConfig: tlsConfig,
+		}), nil
+	case cfg.SentinelMaster != "":
+		if len(cfg.SentinelAddrs) == 0 {
+			return nil, fmt.Errorf("no sentinel addresses configured for master %q", cfg.SentinelMaster)
+		}
+
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    cfg.SentinelMaster,
+			SentinelAddrs: cfg.SentinelAddrs,
+			DB:            db,
+			Password:      password,
+			OnConnect:     onConnect,
+			TLSConfig:     tlsConfig,
+		}), nil
+	}
+
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("no redis host, URL, sentinel or cluster configured")
+	}
+
+	return redis.NewClient(&redis.Options{
+		Addr:      net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		DB:        db,
+		Password:  password,
+		OnConnect: onConnect,
+		TLSConfig: tlsConfig,
+	}), nil
+}
+
+// parseRedisURL replaces the address, database and credentials of the config by the ones of its URL
+func parseRedisURL(cfg *RedisConfig) error {
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("invalid redis URL: %v", err)
+	}
+
+	host, port, err := net.SplitHostPort(opts.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid redis URL: %v", err)
+	}
+
+	cfg.Host = host
+	if cfg.Port, err = strconv.Atoi(port); err != nil {
+		return fmt.Errorf("invalid redis URL port %q", port)
+	}
+	cfg.DB = opts.DB
+	cfg.TLS = cfg.TLS || opts.TLSConfig != nil
+
+	// the redis client ignores the user name of URLs
+	if u, err := url.Parse(cfg.URL); err == nil && u.User != nil {
+		cfg.Username = u.User.Username()
+		cfg.Password = opts.Password
+	}
+
+	return nil
+}
+
+// tlsConfig returns the TLS config of the connection, or nil if TLS isn't enabled
+func (cfg RedisConfig) tlsConfig() (*tls.Config, error) {
+	if !cfg.TLS && cfg.TLSCAFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+	}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read redis CA file: %v", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in redis CA file %q", cfg.TLSCAFile)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// auth returns the password and database the client connects with, or a hook authenticating as ACL user,
+// as the redis client only supports passwords of the default user. The hook selects the database after
+// authenticating, since the client would select it before.
+func (cfg RedisConfig) auth() (string, int, func(*redis.Conn) error) {
+	if cfg.Username == "" || cfg.Password == "" {
+		return cfg.Password, cfg.DB, nil
+	}
+
+	return "", 0, func(cn *redis.Conn) error {
+		cmd := redis.NewStatusCmd("auth", cfg.Username, cfg.Password)
+		if err := cn.Process(cmd); err != nil {
+			return fmt.Errorf("failed to authenticate as %q: %v", cfg.Username, err)
+		}
+
+		if cfg.DB > 0 {
+			return cn.Select(cfg.DB).Err()
+		}
+
+		return nil
+	}
+}
@@ -5,4 +5,6 @@ import "errors"
 var (
 	// ErrNotFound is thrown when the key does not exist
 	ErrNotFound = errors.New("not found")
+	// ErrUnavailable is thrown when the storage can't be reached and the key isn't buffered
+	ErrUnavailable = errors.New("storage unavailable")
 )
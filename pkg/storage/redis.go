@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/go-redis/redis"
 )
@@ -48,3 +49,87 @@ func (r *RedisStorage) Del(key string) error {
 
 	return nil
 }
+
+// AddMember adds a member to a sorted set or updates its score
+func (r *RedisStorage) AddMember(key, member string, score float64) error {
+	if err := r.client.ZAdd(key, redis.Z{Score: score, Member: member}).Err(); err != nil {
+		return fmt.Errorf("failed to add member: %v", err)
+	}
+
+	return nil
+}
+
+// Members returns all members of a sorted set with their scores
+func (r *RedisStorage) Members(key string) (map[string]float64, error) {
+	zs, err := r.client.ZRangeWithScores(key, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch members: %v", err)
+	}
+
+	members := make(map[string]float64, len(zs))
+	for _, z := range zs {
+		members[fmt.Sprintf("%v", z.Member)] = z.Score
+	}
+
+	return members, nil
+}
+
+// RemoveMember removes a member from a sorted set
+func (r *RedisStorage) RemoveMember(key, member string) error {
+	if err := r.client.ZRem(key, member).Err(); err != nil {
+		return fmt.Errorf("failed to remove member: %v", err)
+	}
+
+	return nil
+}
+
+// Keys returns all keys matching a glob pattern. In a Cluster, the keys of all masters are returned.
+func (r *RedisStorage) Keys(pattern string) ([]string, error) {
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		return scanKeys(r.client, pattern)
+	}
+
+	// a scan only covers the keys of the node it runs on
+	var mu sync.Mutex
+	var keys []string
+	err := cluster.ForEachMaster(func(client *redis.Client) error {
+		nodeKeys, err := scanKeys(client, pattern)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		keys = append(keys, nodeKeys...)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func scanKeys(client scanner, pattern string) ([]string, error) {
+	var keys []string
+	it := client.Scan(0, pattern, 100).Iterator()
+	for it.Next() {
+		keys = append(keys, it.Val())
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan keys: %v", err)
+	}
+
+	return keys, nil
+}
+
+// Ping checks the connection to redis
+func (r *RedisStorage) Ping() error {
+	if err := r.client.Ping().Err(); err != nil {
+		return fmt.Errorf("failed to ping redis: %v", err)
+	}
+
+	return nil
+}
@@ -10,4 +10,25 @@ type redisClient interface {
 	Get(key string) *redis.StringCmd
 	Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd
 	Del(keys ...string) *redis.IntCmd
+	ZAdd(key string, members ...redis.Z) *redis.IntCmd
+	ZRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd
+	ZRem(key string, members ...interface{}) *redis.IntCmd
+	Scan(cursor uint64, match string, count int64) *redis.ScanCmd
+	Ping() *redis.StatusCmd
+}
+
+type scanner interface {
+	Scan(cursor uint64, match string, count int64) *redis.ScanCmd
+}
+
+// backend is a storage buffered by Buffered
+type backend interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+	Del(key string) error
+	AddMember(key, member string, score float64) error
+	Members(key string) (map[string]float64, error)
+	RemoveMember(key, member string) error
+	Keys(pattern string) ([]string, error)
+	Ping() error
 }
@@ -0,0 +1,12 @@
+package text
+
+// Truncate shortens a value to the given number of characters, marking it with an ellipsis if it was cut.
+// The length comes first to allow piping values into it in templates.
+func Truncate(length int, value string) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+
+	return string(runes[:length]) + "…"
+}
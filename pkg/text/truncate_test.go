@@ -0,0 +1,50 @@
+package text
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		value  string
+		want   string
+	}{
+		{
+			name:   "short value",
+			length: 5,
+			value:  "abc",
+			want:   "abc",
+		},
+		{
+			name:   "exact length",
+			length: 3,
+			value:  "abc",
+			want:   "abc",
+		},
+		{
+			name:   "long value",
+			length: 2,
+			value:  "abc",
+			want:   "ab…",
+		},
+		{
+			name:   "multi-byte characters",
+			length: 2,
+			value:  "äöü",
+			want:   "äö…",
+		},
+		{
+			name:  "zero length",
+			value: "abc",
+			want:  "…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Truncate(tt.length, tt.value); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
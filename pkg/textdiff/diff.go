@@ -0,0 +1,251 @@
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxCost limits the work of finding a single split of the values, as the number of tokens times the searched
+// edit distance. Parts differing more are reported as a full replacement, bounding the time spent on unrelated values.
+const maxCost = 10 * 1000 * 1000
+
+// Op is the kind of a chunk
+type Op int
+
+const (
+	// Equal marks text present in both values
+	Equal Op = iota
+	// Insert marks text only present in the new value
+	Insert
+	// Delete marks text only present in the old value
+	Delete
+)
+
+// Chunk is a piece of text with the operation turning the old into the new value
+type Chunk struct {
+	Op   Op
+	Text string
+}
+
+// Words diffs two values word by word. Whitespace is kept, so joining all
+// non-deleted chunks returns the new value.
+func Words(oldValue, newValue string) []Chunk {
+	return compute(splitWords(oldValue), splitWords(newValue))
+}
+
+// Lines diffs two values line by line. Every chunk holds a single line including its line break.
+func Lines(oldValue, newValue string) []Chunk {
+	return compute(splitLines(oldValue), splitLines(newValue))
+}
+
+// IsLong reports whether one of the values is long enough to benefit from a text diff
+func IsLong(values ...string) bool {
+	for _, v := range values {
+		if len(v) > 80 || strings.Contains(v, "\n") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitWords(s string) []string {
+	var tokens []string
+	start, space := 0, false
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != space {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+
+	return tokens
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// compute diffs two token lists with the linear space variant of Myers' algorithm
+func compute(a, b []string) []Chunk {
+	d := &differ{oldTokens: a, newTokens: b}
+	d.a, d.b = intern(a, b)
+	d.diff(0, len(a), 0, len(b))
+
+	return d.chunks
+}
+
+// differ holds the tokens of both values as ids, to compare them cheaply
+type differ struct {
+	oldTokens, newTokens []string
+	a, b                 []int
+	chunks               []Chunk
+}
+
+// intern maps equal tokens to equal ids
+func intern(a, b []string) ([]int, []int) {
+	ids := make(map[string]int)
+	toIDs := func(tokens []string) []int {
+		res := make([]int, len(tokens))
+		for i, t := range tokens {
+			id, ok := ids[t]
+			if !ok {
+				id = len(ids)
+				ids[t] = id
+			}
+			res[i] = id
+		}
+		return res
+	}
+
+	return toIDs(a), toIDs(b)
+}
+
+// diff appends the chunks turning a[a0:a1] into b[b0:b1], splitting both at the middle of a shortest edit script
+func (d *differ) diff(a0, a1, b0, b1 int) {
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.chunks = append(d.chunks, Chunk{Op: Equal, Text: d.oldTokens[a0]})
+		a0++
+		b0++
+	}
+
+	suffix := a1
+	for a1 > a0 && b1 > b0 && d.a[a1-1] == d.b[b1-1] {
+		a1--
+		b1--
+	}
+
+	switch {
+	case a0 == a1:
+		d.chunks = appendAll(d.chunks, Insert, d.newTokens[b0:b1])
+	case b0 == b1:
+		d.chunks = appendAll(d.chunks, Delete, d.oldTokens[a0:a1])
+	default:
+		if x, y, ok := d.split(a0, a1, b0, b1); ok {
+			d.diff(a0, x, b0, y)
+			d.diff(x, a1, y, b1)
+		} else {
+			d.chunks = appendAll(d.chunks, Delete, d.oldTokens[a0:a1])
+			d.chunks = appendAll(d.chunks, Insert, d.newTokens[b0:b1])
+		}
+	}
+
+	d.chunks = appendAll(d.chunks, Equal, d.oldTokens[a1:suffix])
+}
+
+// split finds the middle snake of a[a0:a1] and b[b0:b1] by searching forward and backward at once, and returns
+// where it starts. It fails if the parts are too different to be searched within maxCost.
+func (d *differ) split(a0, a1, b0, b1 int) (int, int, bool) {
+	a, b := d.a[a0:a1], d.b[b0:b1]
+	n, m := len(a), len(b)
+
+	maxD := (n + m + 1) / 2
+	if limit := maxCost / (n + m); limit < maxD {
+		maxD = limit
+	}
+
+	offset := (n + m + 1) / 2
+	// forward[offset+k] and backward[offset+k] are the furthest x reached on diagonal k from both ends
+	forward := make([]int, 2*offset+2)
+	backward := make([]int, 2*offset+2)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	// with an odd delta, the paths meet during the forward search, otherwise during the backward one
+	odd := delta%2 != 0
+
+	// diagonals leaving the values on either side are not searched anymore
+	var forwardStart, forwardEnd, backwardStart, backwardEnd int
+
+	for step := 0; step < maxD; step++ {
+		for k := -step + forwardStart; k <= step-forwardEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -step || (k != step && forward[i-1] < forward[i+1]) {
+				x = forward[i+1]
+			} else {
+				x = forward[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[i] = x
+
+			switch {
+			case x > n:
+				forwardEnd += 2
+			case y > m:
+				forwardStart += 2
+			case odd:
+				if j := offset + delta - k; j >= 0 && j < len(backward) && backward[j] != -1 && x >= n-backward[j] {
+					return checkSplit(a0, a1, b0, b1, a0+x, b0+y)
+				}
+			}
+		}
+
+		for k := -step + backwardStart; k <= step-backwardEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -step || (k != step && backward[i-1] < backward[i+1]) {
+				x = backward[i+1]
+			} else {
+				x = backward[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[i] = x
+
+			switch {
+			case x > n:
+				backwardEnd += 2
+			case y > m:
+				backwardStart += 2
+			case !odd:
+				if j := offset + delta - k; j >= 0 && j < len(forward) && forward[j] != -1 && forward[j] >= n-x {
+					fx := forward[j]
+					return checkSplit(a0, a1, b0, b1, a0+fx, b0+fx-(j-offset))
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// checkSplit makes sure a split divides the parts, so diffing them ends
+func checkSplit(a0, a1, b0, b1, x, y int) (int, int, bool) {
+	if (x == a0 && y == b0) || (x == a1 && y == b1) {
+		return 0, 0, false
+	}
+
+	return x, y, true
+}
+
+func appendAll(chunks []Chunk, op Op, tokens []string) []Chunk {
+	for _, t := range tokens {
+		chunks = append(chunks, Chunk{Op: op, Text: t})
+	}
+
+	return chunks
+}
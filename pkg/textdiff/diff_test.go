@@ -0,0 +1,172 @@
+package textdiff
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		oldValue string
+		newValue string
+		want     []Chunk
+	}{
+		{
+			name: "empty values",
+		},
+		{
+			name:     "identical values",
+			oldValue: "same text",
+			newValue: "same text",
+			want:     []Chunk{{Equal, "same"}, {Equal, " "}, {Equal, "text"}},
+		},
+		{
+			name:     "pure insert",
+			newValue: "new text",
+			want:     []Chunk{{Insert, "new"}, {Insert, " "}, {Insert, "text"}},
+		},
+		{
+			name:     "pure delete",
+			oldValue: "old text",
+			want:     []Chunk{{Delete, "old"}, {Delete, " "}, {Delete, "text"}},
+		},
+		{
+			name:     "changed word",
+			oldValue: "the quick fox",
+			newValue: "the slow fox",
+			want:     []Chunk{{Equal, "the"}, {Equal, " "}, {Delete, "quick"}, {Insert, "slow"}, {Equal, " "}, {Equal, "fox"}},
+		},
+		{
+			name:     "inserted words",
+			oldValue: "a c",
+			newValue: "a b c",
+			want:     []Chunk{{Equal, "a"}, {Equal, " "}, {Insert, "b"}, {Insert, " "}, {Equal, "c"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Words(tt.oldValue, tt.newValue)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		oldValue string
+		newValue string
+		want     []Chunk
+	}{
+		{
+			name: "empty values",
+		},
+		{
+			name:     "changed line",
+			oldValue: "a\nb\nc\n",
+			newValue: "a\nB\nc\n",
+			want:     []Chunk{{Equal, "a\n"}, {Delete, "b\n"}, {Insert, "B\n"}, {Equal, "c\n"}},
+		},
+		{
+			name:     "missing final line break",
+			oldValue: "a\nb",
+			newValue: "a\nb\n",
+			want:     []Chunk{{Equal, "a\n"}, {Delete, "b"}, {Insert, "b\n"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.oldValue, tt.newValue)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeMinimal(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", "c", "d"}
+	random := func() []string {
+		tokens := make([]string, r.Intn(20))
+		for i := range tokens {
+			tokens[i] = alphabet[r.Intn(len(alphabet))]
+		}
+		return tokens
+	}
+
+	for i := 0; i < 1000; i++ {
+		a, b := random(), random()
+		chunks := compute(a, b)
+
+		var oldTokens, newTokens []string
+		var equal int
+		for _, c := range chunks {
+			if c.Op != Insert {
+				oldTokens = append(oldTokens, c.Text)
+			}
+			if c.Op != Delete {
+				newTokens = append(newTokens, c.Text)
+			}
+			if c.Op == Equal {
+				equal++
+			}
+		}
+
+		if strings.Join(oldTokens, "") != strings.Join(a, "") || strings.Join(newTokens, "") != strings.Join(b, "") {
+			t.Fatalf("diff of %v and %v does not restore them: %v", a, b, chunks)
+		}
+		if want := lcsLength(a, b); equal != want {
+			t.Fatalf("diff of %v and %v keeps %d tokens, want %d: %v", a, b, equal, want, chunks)
+		}
+	}
+}
+
+func TestComputeLargeValues(t *testing.T) {
+	a := make([]string, 200000)
+	b := make([]string, 200000)
+	for i := range a {
+		a[i] = "a"
+		b[i] = "b"
+	}
+	b[100000] = "a"
+
+	start := time.Now()
+	chunks := compute(a, b)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("diff of unrelated values took %s", elapsed)
+	}
+	if len(chunks) < len(a)+len(b)-2 {
+		t.Errorf("got %d chunks, want at least %d", len(chunks), len(a)+len(b)-2)
+	}
+}
+
+// lcsLength returns the length of the longest common subsequence of both token lists
+func lcsLength(a, b []string) int {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				table[i][j] = table[i+1][j+1] + 1
+			case table[i+1][j] >= table[i][j+1]:
+				table[i][j] = table[i+1][j]
+			default:
+				table[i][j] = table[i][j+1]
+			}
+		}
+	}
+
+	return table[0][0]
+}
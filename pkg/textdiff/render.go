@@ -0,0 +1,142 @@
+package textdiff
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around changes in unified diffs
+const contextLines = 3
+
+// HTML renders a word diff of both values, highlighting insertions and deletions
+func HTML(oldValue, newValue string) string {
+	var b strings.Builder
+	chunks := merge(Words(oldValue, newValue))
+
+	for _, c := range chunks {
+		text := strings.Replace(html.EscapeString(c.Text), "\n", "<br />\n", -1)
+		switch c.Op {
+		case Insert:
+			fmt.Fprintf(&b, `<ins style="background-color: #c8f0c8;">%s</ins>`, text)
+		case Delete:
+			fmt.Fprintf(&b, `<del style="background-color: #f8cbcb;">%s</del>`, text)
+		default:
+			b.WriteString(text)
+		}
+	}
+
+	return b.String()
+}
+
+// Unified renders a line diff of both values in the unified diff format
+func Unified(oldValue, newValue string) string {
+	chunks := Lines(oldValue, newValue)
+
+	var b strings.Builder
+	b.WriteString("--- old\n+++ new\n")
+
+	for start := 0; start < len(chunks); {
+		first := nextChange(chunks, start)
+		if first < 0 {
+			break
+		}
+
+		// extend the hunk as long as the next change is within reach of the context
+		last := first
+		for {
+			next := nextChange(chunks, last+1)
+			if next < 0 || next-last > 2*contextLines {
+				break
+			}
+			last = next
+		}
+
+		from := max(first-contextLines, start)
+		to := min(last+contextLines+1, len(chunks))
+		writeHunk(&b, chunks, from, to)
+		start = to
+	}
+
+	return b.String()
+}
+
+func writeHunk(b *strings.Builder, chunks []Chunk, from, to int) {
+	oldLine, newLine := 1, 1
+	for _, c := range chunks[:from] {
+		if c.Op != Insert {
+			oldLine++
+		}
+		if c.Op != Delete {
+			newLine++
+		}
+	}
+
+	var oldCount, newCount int
+	var lines strings.Builder
+	for _, c := range chunks[from:to] {
+		prefix := " "
+		switch c.Op {
+		case Insert:
+			prefix = "+"
+			newCount++
+		case Delete:
+			prefix = "-"
+			oldCount++
+		default:
+			oldCount++
+			newCount++
+		}
+
+		lines.WriteString(prefix + strings.TrimSuffix(c.Text, "\n") + "\n")
+	}
+
+	// empty ranges point at the line before them
+	if oldCount == 0 {
+		oldLine--
+	}
+	if newCount == 0 {
+		newLine--
+	}
+
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+	b.WriteString(lines.String())
+}
+
+func nextChange(chunks []Chunk, from int) int {
+	for i := from; i < len(chunks); i++ {
+		if chunks[i].Op != Equal {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// merge joins consecutive chunks with the same operation
+func merge(chunks []Chunk) []Chunk {
+	var res []Chunk
+	for _, c := range chunks {
+		if len(res) > 0 && res[len(res)-1].Op == c.Op {
+			res[len(res)-1].Text += c.Text
+			continue
+		}
+		res = append(res, c)
+	}
+
+	return res
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,105 @@
+package textdiff
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		oldValue string
+		newValue string
+		want     string
+	}{
+		{
+			name: "empty values",
+		},
+		{
+			name:     "identical values",
+			oldValue: "same",
+			newValue: "same",
+			want:     "same",
+		},
+		{
+			name:     "changed words",
+			oldValue: "price 10 EUR",
+			newValue: "price 12 EUR",
+			want:     `price <del style="background-color: #f8cbcb;">10</del><ins style="background-color: #c8f0c8;">12</ins> EUR`,
+		},
+		{
+			name:     "escaped html",
+			oldValue: "<b>old</b>",
+			newValue: "<b>new</b> & more",
+			want:     `<del style="background-color: #f8cbcb;">&lt;b&gt;old&lt;/b&gt;</del><ins style="background-color: #c8f0c8;">&lt;b&gt;new&lt;/b&gt; &amp; more</ins>`,
+		},
+		{
+			name:     "line breaks",
+			oldValue: "a\nb",
+			newValue: "a\nc",
+			want:     "a<br />\n" + `<del style="background-color: #f8cbcb;">b</del><ins style="background-color: #c8f0c8;">c</ins>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.oldValue, tt.newValue); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		oldValue string
+		newValue string
+		want     string
+	}{
+		{
+			name: "empty values",
+			want: "--- old\n+++ new\n",
+		},
+		{
+			name:     "identical values",
+			oldValue: "a\nb\n",
+			newValue: "a\nb\n",
+			want:     "--- old\n+++ new\n",
+		},
+		{
+			name:     "pure insert",
+			newValue: "a\nb\n",
+			want:     "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:     "pure delete",
+			oldValue: "a\nb\n",
+			want:     "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name:     "context around change",
+			oldValue: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			newValue: "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want:     "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:     "separate hunks",
+			oldValue: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			newValue: "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{
+			name:     "joined hunks",
+			oldValue: "1\n2\n3\n4\n5\n6\n7\n",
+			newValue: "one\n2\n3\n4\n5\n6\nseven\n",
+			want:     "--- old\n+++ new\n@@ -1,7 +1,7 @@\n-1\n+one\n 2\n 3\n 4\n 5\n 6\n-7\n+seven\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified(tt.oldValue, tt.newValue); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+// checkCollections validates the collections of a job
+func checkCollections(job *api.Job) error {
+	for name, cfg := range job.Collections {
+		if cfg.Identity == "" {
+			return fmt.Errorf("collection %q has no identity field", name)
+		}
+	}
+
+	return nil
+}
+
+// collectionItem returns the item name of a record of a collection, e.g. "postings[42]"
+func collectionItem(collection, id string) string {
+	return collection + "[" + id + "]"
+}
+
+// collectionOf returns the collection an item is a record of
+func collectionOf(job *api.Job, item string) (string, bool) {
+	for name := range job.Collections {
+		if strings.HasPrefix(item, name+"[") && strings.HasSuffix(item, "]") {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// expandCollections replaces the array results of the job's collections by one item per record.
+// Records are stored as JSON objects of their fields, so they can be compared field by field.
+func expandCollections(job *api.Job, results map[string]interface{}) (map[string]interface{}, error) {
+	if len(job.Collections) == 0 {
+		return results, nil
+	}
+
+	res := make(map[string]interface{}, len(results))
+	for key, value := range results {
+		res[key] = value
+	}
+
+	for name, cfg := range job.Collections {
+		raw, ok := results[name]
+		if !ok {
+			return nil, fmt.Errorf("collection %q is missing in the results", name)
+		}
+		delete(res, name)
+
+		records, ok := raw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("collection %q is not an array", name)
+		}
+
+		for i, r := range records {
+			record, ok := r.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("record %d of collection %q is not an object", i, name)
+			}
+
+			id, ok := record[cfg.Identity]
+			if !ok || id == nil {
+				return nil, fmt.Errorf("record %d of collection %q has no identity field %q", i, name, cfg.Identity)
+			}
+
+			item := collectionItem(name, fieldValue(id))
+			if _, ok := res[item]; ok {
+				return nil, fmt.Errorf("record %q of collection %q is not unique", fieldValue(id), name)
+			}
+
+			value, err := recordValue(cfg, record)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode record %q of collection %q: %v", fieldValue(id), name, err)
+			}
+			res[item] = value
+		}
+	}
+
+	return res, nil
+}
+
+// recordValue encodes the compared fields of a record as JSON object
+func recordValue(cfg api.CollectionConfig, record map[string]interface{}) (string, error) {
+	fields := make(map[string]string, len(record))
+	for field, value := range record {
+		fields[field] = fieldValue(value)
+	}
+
+	if len(cfg.Fields) > 0 {
+		selected := map[string]string{cfg.Identity: fields[cfg.Identity]}
+		for _, field := range cfg.Fields {
+			if value, ok := fields[field]; ok {
+				selected[field] = value
+			}
+		}
+		fields = selected
+	}
+
+	b, err := json.Marshal(fields)
+	return string(b), err
+}
+
+// fieldValue formats a field of a record. Numbers are formatted without exponent, so identities like 1234567
+// stay readable, nested values are kept as JSON.
+func fieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	case map[string]interface{}, []interface{}:
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+// removedItems returns all items and records of collections not part of the results anymore
+func removedItems(newValues, oldValues map[string]string) []api.Diff {
+	var diff []api.Diff
+	for item, oldVal := range oldValues {
+		if _, ok := newValues[item]; ok {
+			continue
+		}
+
+		diff = append(diff, api.Diff{
+			Item:     item,
+			OldValue: oldVal,
+			Kind:     api.DiffRemoved,
+		})
+	}
+
+	return diff
+}
+
+// annotateRecords sets the collection of changed records and the changes of their fields
+func annotateRecords(job *api.Job, diff []api.Diff) []api.Diff {
+	for i, d := range diff {
+		collection, ok := collectionOf(job, d.Item)
+		if !ok {
+			continue
+		}
+
+		diff[i].Collection = collection
+		if d.Kind == api.DiffChanged {
+			diff[i].Fields = fieldChanges(d.OldValue, d.NewValue)
+		}
+	}
+
+	return diff
+}
+
+// fieldChanges compares two records field by field
+func fieldChanges(oldValue, newValue string) []api.FieldChange {
+	var oldFields, newFields map[string]string
+	if json.Unmarshal([]byte(oldValue), &oldFields) != nil || json.Unmarshal([]byte(newValue), &newFields) != nil {
+		return nil
+	}
+
+	var names []string
+	for field := range oldFields {
+		names = append(names, field)
+	}
+	for field := range newFields {
+		if _, ok := oldFields[field]; !ok {
+			names = append(names, field)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []api.FieldChange
+	for _, field := range names {
+		if oldFields[field] != newFields[field] {
+			changes = append(changes, api.FieldChange{
+				Field:    field,
+				OldValue: oldFields[field],
+				NewValue: newFields[field],
+			})
+		}
+	}
+
+	return changes
+}
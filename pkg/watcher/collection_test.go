@@ -0,0 +1,146 @@
+package watcher
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestExpandCollections(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     api.CollectionConfig
+		records []interface{}
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "string identity",
+			cfg:  api.CollectionConfig{Identity: "id"},
+			records: []interface{}{
+				map[string]interface{}{"id": "a", "title": "First"},
+				map[string]interface{}{"id": "b", "title": "Second"},
+			},
+			want: map[string]interface{}{
+				"postings[a]": `{"id":"a","title":"First"}`,
+				"postings[b]": `{"id":"b","title":"Second"}`,
+			},
+		},
+		{
+			name: "numeric identity",
+			cfg:  api.CollectionConfig{Identity: "id"},
+			records: []interface{}{
+				map[string]interface{}{"id": float64(1234567), "price": 12.5},
+				map[string]interface{}{"id": float64(1234568), "price": float64(20000000)},
+			},
+			want: map[string]interface{}{
+				"postings[1234567]": `{"id":"1234567","price":"12.5"}`,
+				"postings[1234568]": `{"id":"1234568","price":"20000000"}`,
+			},
+		},
+		{
+			name:    "selected fields",
+			cfg:     api.CollectionConfig{Identity: "id", Fields: []string{"title"}},
+			records: []interface{}{map[string]interface{}{"id": "a", "title": "First", "views": float64(3)}},
+			want:    map[string]interface{}{"postings[a]": `{"id":"a","title":"First"}`},
+		},
+		{
+			name:    "nested fields",
+			cfg:     api.CollectionConfig{Identity: "id"},
+			records: []interface{}{map[string]interface{}{"id": "a", "tags": []interface{}{"x", "y"}}},
+			want:    map[string]interface{}{"postings[a]": `{"id":"a","tags":"[\"x\",\"y\"]"}`},
+		},
+		{
+			name: "duplicate identity",
+			cfg:  api.CollectionConfig{Identity: "id"},
+			records: []interface{}{
+				map[string]interface{}{"id": float64(1), "title": "First"},
+				map[string]interface{}{"id": float64(1), "title": "Second"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "missing identity",
+			cfg:     api.CollectionConfig{Identity: "id"},
+			records: []interface{}{map[string]interface{}{"title": "First"}},
+			wantErr: true,
+		},
+		{
+			name:    "record not an object",
+			cfg:     api.CollectionConfig{Identity: "id"},
+			records: []interface{}{"First"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &api.Job{Collections: map[string]api.CollectionConfig{"postings": tt.cfg}}
+			results := map[string]interface{}{"title": "Jobs", "postings": tt.records}
+
+			got, err := expandCollections(job, results)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			tt.want["title"] = "Jobs"
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemovedItems(t *testing.T) {
+	job := &api.Job{Collections: map[string]api.CollectionConfig{"postings": {Identity: "id"}}}
+
+	tests := []struct {
+		name      string
+		newValues map[string]string
+		oldValues map[string]string
+		want      []api.Diff
+	}{
+		{
+			name:      "removed record",
+			newValues: map[string]string{"title": "Jobs"},
+			oldValues: map[string]string{"title": "Jobs", "postings[1]": `{"id":"1"}`},
+			want:      []api.Diff{{Item: "postings[1]", OldValue: `{"id":"1"}`, Kind: api.DiffRemoved, Collection: "postings"}},
+		},
+		{
+			name:      "removed plain item",
+			newValues: map[string]string{},
+			oldValues: map[string]string{"title": "Jobs"},
+			want:      []api.Diff{{Item: "title", OldValue: "Jobs", Kind: api.DiffRemoved}},
+		},
+		{
+			name:      "kept record",
+			newValues: map[string]string{"postings[1]": `{"id":"1"}`},
+			oldValues: map[string]string{"postings[1]": `{"id":"1"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := annotateRecords(job, removedItems(tt.newValues, tt.oldValues))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldChanges(t *testing.T) {
+	got := fieldChanges(`{"id":"1","price":"10","title":"a"}`, `{"id":"1","price":"12","stock":"yes","title":"a"}`)
+	want := []api.FieldChange{
+		{Field: "price", OldValue: "10", NewValue: "12"},
+		{Field: "stock", NewValue: "yes"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
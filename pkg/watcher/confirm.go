@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+// pendingValue is a changed value waiting to be confirmed by consecutive runs
+type pendingValue struct {
+	Value string `json:"value"`
+	Runs  int    `json:"runs"`
+}
+
+// confirmChanges holds back changes until they have been observed on job.ConfirmRuns consecutive runs.
+// It returns the confirmed diff, the values to store as new baseline and the still pending values.
+func (w *Watcher) confirmChanges(job *api.Job, diff []api.Diff, newValues, oldValues, hashes map[string]string) ([]api.Diff, map[string]string, map[string]pendingValue, error) {
+	pending, err := w.getPending(job.Name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	baseline := make(map[string]string, len(newValues))
+	for key, val := range newValues {
+		baseline[key] = val
+	}
+
+	confirmed := make([]api.Diff, 0, len(diff))
+	stillPending := make(map[string]pendingValue)
+
+	for _, d := range diff {
+		runs, err := w.observedRuns(job, d, pending, hashes)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if runs >= job.ConfirmRuns {
+			confirmed = append(confirmed, d)
+			continue
+		}
+
+		w.logger.Debugf("Change of item %q in job %q observed %d/%d times", d.Item, job.Name, runs, job.ConfirmRuns)
+		stillPending[d.Item] = pendingValue{Value: d.NewValue, Runs: runs}
+
+		if oldVal, ok := oldValues[d.Item]; ok {
+			baseline[d.Item] = oldVal
+		} else {
+			delete(baseline, d.Item)
+		}
+	}
+
+	return confirmed, baseline, stillPending, nil
+}
+
+// observedRuns returns on how many consecutive runs the new value of a diff has been seen, including the current one
+func (w *Watcher) observedRuns(job *api.Job, d api.Diff, pending map[string]pendingValue, hashes map[string]string) (int, error) {
+	p, ok := pending[d.Item]
+	if !ok {
+		return 1, nil
+	}
+
+	candidate, err := w.compareValue(job, d.Item, d.NewValue, hashes)
+	if err != nil {
+		return 0, err
+	}
+
+	previous, err := w.compareValue(job, d.Item, p.Value, hashes)
+	if err != nil {
+		return 0, err
+	}
+
+	if candidate != previous {
+		return 1, nil
+	}
+
+	return p.Runs + 1, nil
+}
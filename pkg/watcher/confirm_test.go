@@ -0,0 +1,109 @@
+package watcher
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestProcessConfirmChanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		prices      []string
+		wantChanges []int
+		wantStored  []string
+		wantPending []int
+	}{
+		{
+			name:        "confirmed change",
+			prices:      []string{"2", "2"},
+			wantChanges: []int{0, 1},
+			wantStored:  []string{"1", "2"},
+			wantPending: []int{1, 0},
+		},
+		{
+			name:        "flipping value",
+			prices:      []string{"2", "1", "2", "1"},
+			wantChanges: []int{0, 0, 0, 0},
+			wantStored:  []string{"1", "1", "1", "1"},
+			wantPending: []int{1, 0, 1, 0},
+		},
+		{
+			name:        "pending value changed",
+			prices:      []string{"2", "3", "3"},
+			wantChanges: []int{0, 0, 1},
+			wantStored:  []string{"1", "1", "3"},
+			wantPending: []int{1, 1, 0},
+		},
+		{
+			name:        "confirmed removal",
+			prices:      []string{"", ""},
+			wantChanges: []int{0, 1},
+			wantStored:  []string{"1", ""},
+			wantPending: []int{1, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := api.Job{
+				Name:               "job",
+				ConfirmRuns:        2,
+				NotifyOnChangeOnly: true,
+				Notify:             []api.NotifyEntry{{Type: "test"}},
+			}
+			w := newTestWatcher(t, newMemStorage(), job)
+			n := &recordingNotifier{}
+			if err := w.AddNotifier(n); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.setValues(job.Name, map[string]string{"title": "shop", "price": "1"}); err != nil {
+				t.Fatal(err)
+			}
+
+			var changes, pendingRuns []int
+			var stored []string
+			for _, price := range tt.prices {
+				results := map[string]interface{}{"title": "shop"}
+				if price != "" {
+					results["price"] = price
+				}
+
+				before := len(n.notifications)
+				if err := w.process(context.Background(), &w.config.Jobs[0], api.Run{}, results); err != nil {
+					t.Fatal(err)
+				}
+
+				count := 0
+				for _, notification := range n.notifications[before:] {
+					count += len(notification.Diff)
+				}
+				changes = append(changes, count)
+
+				values, err := w.State(job.Name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				stored = append(stored, values["price"])
+
+				pending, err := w.getPending(job.Name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pendingRuns = append(pendingRuns, pending["price"].Runs)
+			}
+
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("got changes per run %v, want %v", changes, tt.wantChanges)
+			}
+			if !reflect.DeepEqual(stored, tt.wantStored) {
+				t.Errorf("got stored prices %q, want %q", stored, tt.wantStored)
+			}
+			if !reflect.DeepEqual(pendingRuns, tt.wantPending) {
+				t.Errorf("got pending runs %v, want %v", pendingRuns, tt.wantPending)
+			}
+		})
+	}
+}
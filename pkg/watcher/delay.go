@@ -0,0 +1,287 @@
+package watcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+	"github.com/robfig/cron"
+)
+
+// delayedQueueKey holds all notifications delayed by quiet windows
+const delayedQueueKey = globalKeyPrefix + "delayed:queue"
+
+// delayedCheckSchedule is how often delayed notifications are checked for being due
+const delayedCheckSchedule = "@every 1m"
+
+// delayedItem is a notification held back until the end of a quiet window
+type delayedItem struct {
+	Until time.Time       `json:"until"`
+	Entry api.NotifyEntry `json:"entry"`
+	Job   string          `json:"job"`
+	Run   api.DigestRun   `json:"run"`
+	// Initial is set if the notification includes the initial summary of the job
+	Initial bool `json:"initial,omitempty"`
+}
+
+// quiet handles a notification during an active quiet window
+func (w *Watcher) quiet(job *api.Job, notify api.NotifyEntry, window *quietWindow, until time.Time, run api.Run, diff []api.Diff, values map[string]string, initial bool) error {
+	switch window.action {
+	case windowDelay:
+		w.logger.Infof("Delaying notification by %q of job %q until %s", notify.Type, job.Name, until.Format(time.RFC3339))
+		if err := w.queueDelayed(job, notify, until, run, diff, values, initial); err != nil {
+			return fmt.Errorf("failed to delay notification by %q: %v", notify.Type, err)
+		}
+	case windowDigest:
+		if len(diff) == 0 {
+			return nil
+		}
+
+		w.logger.Infof("Adding notification by %q of job %q to the next digest", notify.Type, job.Name)
+		if err := w.queueDigest(job, notify, run, diff, values); err != nil {
+			return fmt.Errorf("failed to queue digest for %q: %v", notify.Type, err)
+		}
+	default:
+		w.logger.Infof("Dropping notification by %q of job %q during quiet window", notify.Type, job.Name)
+	}
+
+	return nil
+}
+
+// queueDelayed stores a notification until the given time. A notification already delayed for the job and the
+// recipients of the notify entry is updated instead, so a quiet window ends with a single notification.
+func (w *Watcher) queueDelayed(job *api.Job, entry api.NotifyEntry, until time.Time, run api.Run, diff []api.Diff, values map[string]string, initial bool) error {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+
+	var queue []delayedItem
+	if err := w.getJSON(delayedQueueKey, &queue); err != nil {
+		return fmt.Errorf("failed to load delayed queue: %v", err)
+	}
+
+	if i := w.findDelayed(queue, delayedItem{Entry: entry, Job: job.Name}); i >= 0 {
+		item := queue[i]
+		if until.After(item.Until) {
+			queue[i].Until = until
+		}
+		queue[i].Entry = entry
+		queue[i].Initial = item.Initial || initial
+		queue[i].Run = api.DigestRun{
+			Run:    run,
+			Diff:   mergeDiffs(job, item.Run.Diff, diff),
+			Values: values,
+		}
+
+		return w.setJSON(delayedQueueKey, queue)
+	}
+
+	queue = append(queue, delayedItem{
+		Until: until,
+		Entry: entry,
+		Job:   job.Name,
+		Run: api.DigestRun{
+			Run:    run,
+			Diff:   diff,
+			Values: values,
+		},
+		Initial: initial,
+	})
+
+	return w.setJSON(delayedQueueKey, queue)
+}
+
+// mergeDiffs combines the diff of a delayed notification with the one of a later run, so it reports the changes
+// since the values the first one started from. Items changed back to their first value are dropped.
+func mergeDiffs(job *api.Job, first, next []api.Diff) []api.Diff {
+	merged := make([]api.Diff, 0, len(first)+len(next))
+	index := make(map[string]int, len(first))
+	for _, d := range first {
+		index[d.Item] = len(merged)
+		merged = append(merged, api.Diff{Item: d.Item, OldValue: d.OldValue, NewValue: d.NewValue, Kind: d.Kind})
+	}
+
+	for _, d := range next {
+		i, ok := index[d.Item]
+		if !ok {
+			merged = append(merged, api.Diff{Item: d.Item, OldValue: d.OldValue, NewValue: d.NewValue, Kind: d.Kind})
+			continue
+		}
+
+		existed, exists := merged[i].Kind != api.DiffAdded, d.Kind != api.DiffRemoved
+		switch {
+		case !existed && !exists:
+			merged[i].Kind = ""
+		case !existed:
+			merged[i].NewValue = d.NewValue
+		case !exists:
+			merged[i].NewValue, merged[i].Kind = "", api.DiffRemoved
+		case merged[i].OldValue == d.NewValue:
+			merged[i].Kind = ""
+		default:
+			merged[i].NewValue, merged[i].Kind = d.NewValue, api.DiffChanged
+		}
+	}
+
+	res := merged[:0]
+	for _, d := range merged {
+		if d.Kind != "" {
+			res = append(res, d)
+		}
+	}
+
+	return annotateRecords(job, annotateTypes(job, res))
+}
+
+// SendDelayed sends all delayed notifications whose quiet window has ended. Failed ones are queued again.
+func (w *Watcher) SendDelayed() error {
+	due, err := w.popDelayed(time.Now())
+	if err != nil {
+		return err
+	}
+
+	var failed []delayedItem
+	for _, item := range due {
+		job := w.findJob(item.Job)
+		if job == nil {
+			w.logger.Warnf("Dropping delayed notification of unknown job %q", item.Job)
+			continue
+		}
+
+		notification := w.newNotification(job, item.Entry, item.Run.Run, item.Run.Diff, item.Run.Values)
+		notification.Initial = item.Initial
+		if err := w.send(item.Entry.Type, []api.Notification{notification}); err != nil {
+			w.logger.Errorf("failed to send delayed notification by %q of job %q: %v", item.Entry.Type, item.Job, err)
+			failed = append(failed, item)
+		}
+	}
+
+	if len(due) > 0 {
+		w.logger.Infof("Sent %d of %d delayed notifications", len(due)-len(failed), len(due))
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	if err := w.requeueDelayed(failed); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("failed to send %d delayed notifications", len(failed))
+}
+
+// popDelayed removes all notifications due at the given time from the queue, so they are sent without holding the lock
+func (w *Watcher) popDelayed(now time.Time) ([]delayedItem, error) {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+
+	var queue []delayedItem
+	if err := w.getJSON(delayedQueueKey, &queue); err != nil {
+		return nil, fmt.Errorf("failed to load delayed queue: %v", err)
+	}
+
+	var due, keep []delayedItem
+	for _, item := range queue {
+		if now.Before(item.Until) {
+			keep = append(keep, item)
+		} else {
+			due = append(due, item)
+		}
+	}
+
+	if len(due) == 0 {
+		return nil, nil
+	}
+
+	if len(keep) == 0 {
+		return due, w.storage.Del(delayedQueueKey)
+	}
+
+	if err := w.setJSON(delayedQueueKey, keep); err != nil {
+		return nil, fmt.Errorf("failed to store delayed queue: %v", err)
+	}
+
+	return due, nil
+}
+
+// requeueDelayed adds notifications which failed to be sent to the queue again. Notifications delayed
+// meanwhile for the same job and recipients are merged into them.
+func (w *Watcher) requeueDelayed(items []delayedItem) error {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+
+	var queue []delayedItem
+	if err := w.getJSON(delayedQueueKey, &queue); err != nil {
+		return fmt.Errorf("failed to load delayed queue: %v", err)
+	}
+
+	for _, item := range queue {
+		if i := w.findDelayed(items, item); i >= 0 {
+			items[i].Until = item.Until
+			items[i].Entry = item.Entry
+			items[i].Initial = items[i].Initial || item.Initial
+			items[i].Run = api.DigestRun{
+				Run:    item.Run.Run,
+				Diff:   mergeDiffs(w.findJob(item.Job), items[i].Run.Diff, item.Run.Diff),
+				Values: item.Run.Values,
+			}
+			continue
+		}
+		items = append(items, item)
+	}
+
+	if err := w.setJSON(delayedQueueKey, items); err != nil {
+		return fmt.Errorf("failed to store delayed queue: %v", err)
+	}
+
+	return nil
+}
+
+// findDelayed returns the index of the notification delayed for the job and the recipients of an item, or -1
+func (w *Watcher) findDelayed(queue []delayedItem, item delayedItem) int {
+	key, err := recipientKey(item.Entry)
+	if err != nil || w.findJob(item.Job) == nil {
+		return -1
+	}
+
+	for i, queued := range queue {
+		if queuedKey, err := recipientKey(queued.Entry); err == nil && queued.Job == item.Job && queuedKey == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// findJob returns the configured job with the given name
+func (w *Watcher) findJob(name string) *api.Job {
+	for i := range w.config.Jobs {
+		if w.config.Jobs[i].Name == name {
+			return &w.config.Jobs[i]
+		}
+	}
+
+	return nil
+}
+
+func (w *Watcher) delayedCronFunc() func() {
+	return func() {
+		if err := w.SendDelayed(); err != nil {
+			w.logger.Error(err)
+		}
+	}
+}
+
+// registerDelayed registers sending delayed notifications at the given cron instance, if any quiet window delays them
+func (w *Watcher) registerDelayed(c *cron.Cron) error {
+	if !w.usesDelay() {
+		return nil
+	}
+
+	w.logger.Debugf("Adding delayed notifications with pattern %q", delayedCheckSchedule)
+	if err := c.AddFunc(delayedCheckSchedule, w.delayedCronFunc()); err != nil {
+		return fmt.Errorf("failed to register cron for delayed notifications: %v", err)
+	}
+
+	return nil
+}
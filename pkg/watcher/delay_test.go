@@ -0,0 +1,258 @@
+package watcher
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+// queueingNotifier queues another delayed notification while sending, like a run finishing meanwhile
+type queueingNotifier struct {
+	w    *Watcher
+	job  *api.Job
+	fail bool
+	sent int
+}
+
+func (n *queueingNotifier) Key() string {
+	return "test"
+}
+
+func (n *queueingNotifier) Notify(notification api.Notification) error {
+	entry := api.NotifyEntry{Type: "test", Value: "later"}
+	if err := n.w.queueDelayed(n.job, entry, time.Now().Add(time.Hour), api.Run{}, nil, nil, false); err != nil {
+		return err
+	}
+
+	if n.fail {
+		return errors.New("failed")
+	}
+	n.sent++
+	return nil
+}
+
+func TestSendDelayed(t *testing.T) {
+	tests := []struct {
+		name      string
+		fail      bool
+		wantErr   bool
+		wantSent  int
+		wantQueue []string
+	}{
+		{
+			name:      "sends due notifications",
+			wantSent:  1,
+			wantQueue: []string{"pending", "later"},
+		},
+		{
+			name:      "queues failed notifications again",
+			fail:      true,
+			wantErr:   true,
+			wantQueue: []string{"due", "pending", "later"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := api.Job{Name: "job"}
+			w := newTestWatcher(t, newMemStorage(), job)
+			n := &queueingNotifier{w: w, job: &w.config.Jobs[0], fail: tt.fail}
+			if err := w.AddNotifier(n); err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Now()
+			for value, until := range map[string]time.Time{"due": now.Add(-time.Minute), "pending": now.Add(time.Hour)} {
+				entry := api.NotifyEntry{Type: "test", Value: value}
+				if err := w.queueDelayed(&w.config.Jobs[0], entry, until, api.Run{}, nil, nil, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				done <- w.SendDelayed()
+			}()
+
+			select {
+			case err := <-done:
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("sending delayed notifications blocked queueing new ones")
+			}
+
+			if n.sent != tt.wantSent {
+				t.Errorf("got %d sent notifications, want %d", n.sent, tt.wantSent)
+			}
+
+			var queue []delayedItem
+			if err := w.getJSON(delayedQueueKey, &queue); err != nil {
+				t.Fatal(err)
+			}
+			values := make(map[string]bool)
+			for _, item := range queue {
+				values[item.Entry.Value] = true
+			}
+			if len(queue) != len(tt.wantQueue) {
+				t.Errorf("got %d queued notifications, want %v", len(queue), tt.wantQueue)
+			}
+			for _, value := range tt.wantQueue {
+				if !values[value] {
+					t.Errorf("notification %q is not queued", value)
+				}
+			}
+		})
+	}
+}
+
+func TestQueueDelayedCoalescesRuns(t *testing.T) {
+	job := api.Job{
+		Name: "job",
+		Notify: []api.NotifyEntry{{
+			Type:         "test",
+			Value:        "user@example.com",
+			QuietWindows: []api.QuietWindow{{From: "00:00", To: "00:00", Action: windowDelay}},
+		}},
+	}
+	w := newTestWatcher(t, newMemStorage(), job)
+	n := &recordingNotifier{}
+	if err := w.AddNotifier(n); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := []struct {
+		diff   []api.Diff
+		values map[string]string
+	}{
+		{
+			diff: []api.Diff{
+				{Item: "price", OldValue: "10", NewValue: "12", Kind: api.DiffChanged},
+				{Item: "title", OldValue: "a", NewValue: "b", Kind: api.DiffChanged},
+			},
+			values: map[string]string{"price": "12", "title": "b"},
+		},
+		{
+			diff: []api.Diff{
+				{Item: "price", OldValue: "12", NewValue: "15", Kind: api.DiffChanged},
+				{Item: "stock", NewValue: "yes", Kind: api.DiffAdded},
+			},
+			values: map[string]string{"price": "15", "title": "b", "stock": "yes"},
+		},
+		{
+			diff: []api.Diff{
+				{Item: "title", OldValue: "b", NewValue: "a", Kind: api.DiffChanged},
+			},
+			values: map[string]string{"price": "15", "title": "a", "stock": "yes"},
+		},
+	}
+	for i, run := range runs {
+		if err := w.notify(&w.config.Jobs[0], api.Run{ID: string(rune('1' + i))}, run.diff, run.values); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var queue []delayedItem
+	if err := w.getJSON(delayedQueueKey, &queue); err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 1 {
+		t.Fatalf("got %d delayed notifications, want 1", len(queue))
+	}
+
+	// the window ends
+	queue[0].Until = time.Now().Add(-time.Minute)
+	if err := w.setJSON(delayedQueueKey, queue); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SendDelayed(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(n.notifications) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(n.notifications))
+	}
+	got := n.notifications[0]
+	if got.Run.ID != "3" {
+		t.Errorf("got run %q, want the latest one", got.Run.ID)
+	}
+	if !reflect.DeepEqual(got.Values, runs[2].values) {
+		t.Errorf("got values %v, want the latest ones", got.Values)
+	}
+
+	var diff []string
+	for _, d := range got.Diff {
+		diff = append(diff, d.Item+" "+string(d.Kind)+" "+d.OldValue+" -> "+d.NewValue)
+	}
+	wantDiff := []string{"price changed 10 -> 15", "stock added  -> yes"}
+	if !reflect.DeepEqual(diff, wantDiff) {
+		t.Errorf("got diff %q, want %q", diff, wantDiff)
+	}
+}
+
+func TestMergeDiffs(t *testing.T) {
+	tests := []struct {
+		name  string
+		first []api.Diff
+		next  []api.Diff
+		want  []api.Diff
+	}{
+		{
+			name:  "changed twice",
+			first: []api.Diff{{Item: "a", OldValue: "1", NewValue: "2", Kind: api.DiffChanged}},
+			next:  []api.Diff{{Item: "a", OldValue: "2", NewValue: "3", Kind: api.DiffChanged}},
+			want:  []api.Diff{{Item: "a", OldValue: "1", NewValue: "3", Kind: api.DiffChanged, Type: typeString}},
+		},
+		{
+			name:  "changed back",
+			first: []api.Diff{{Item: "a", OldValue: "1", NewValue: "2", Kind: api.DiffChanged}},
+			next:  []api.Diff{{Item: "a", OldValue: "2", NewValue: "1", Kind: api.DiffChanged}},
+			want:  []api.Diff{},
+		},
+		{
+			name:  "added and changed",
+			first: []api.Diff{{Item: "a", NewValue: "1", Kind: api.DiffAdded}},
+			next:  []api.Diff{{Item: "a", OldValue: "1", NewValue: "2", Kind: api.DiffChanged}},
+			want:  []api.Diff{{Item: "a", NewValue: "2", Kind: api.DiffAdded, Type: typeString}},
+		},
+		{
+			name:  "added and removed",
+			first: []api.Diff{{Item: "a", NewValue: "1", Kind: api.DiffAdded}},
+			next:  []api.Diff{{Item: "a", OldValue: "1", Kind: api.DiffRemoved}},
+			want:  []api.Diff{},
+		},
+		{
+			name:  "changed and removed",
+			first: []api.Diff{{Item: "a", OldValue: "1", NewValue: "2", Kind: api.DiffChanged}},
+			next:  []api.Diff{{Item: "a", OldValue: "2", Kind: api.DiffRemoved}},
+			want:  []api.Diff{{Item: "a", OldValue: "1", Kind: api.DiffRemoved, Type: typeString}},
+		},
+		{
+			name:  "removed and added again",
+			first: []api.Diff{{Item: "a", OldValue: "1", Kind: api.DiffRemoved}},
+			next:  []api.Diff{{Item: "a", NewValue: "2", Kind: api.DiffAdded}},
+			want:  []api.Diff{{Item: "a", OldValue: "1", NewValue: "2", Kind: api.DiffChanged, Type: typeString}},
+		},
+		{
+			name:  "separate items",
+			first: []api.Diff{{Item: "a", NewValue: "1", Kind: api.DiffAdded}},
+			next:  []api.Diff{{Item: "b", NewValue: "2", Kind: api.DiffAdded}},
+			want: []api.Diff{
+				{Item: "a", NewValue: "1", Kind: api.DiffAdded, Type: typeString},
+				{Item: "b", NewValue: "2", Kind: api.DiffAdded, Type: typeString},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeDiffs(&api.Job{}, tt.first, tt.next); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
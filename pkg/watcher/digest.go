@@ -0,0 +1,249 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+	"github.com/robfig/cron"
+)
+
+// digestQueueKey holds all queued digest runs
+const digestQueueKey = globalKeyPrefix + "digest:queue"
+
+// digestItem is a run queued for the digest of a notify entry
+type digestItem struct {
+	Entry api.NotifyEntry `json:"entry"`
+	Job   string          `json:"job"`
+	Run   api.DigestRun   `json:"run"`
+}
+
+// checkDigest validates the digest settings of a job
+func (w *Watcher) checkDigest(job *api.Job) error {
+	for _, notify := range job.Notify {
+		if !notify.Digest {
+			continue
+		}
+
+		if err := w.checkDigestEntry(notify); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkDigestEntry validates that digests can be sent for a notify entry
+func (w *Watcher) checkDigestEntry(notify api.NotifyEntry) error {
+	if w.config.Digest == nil || w.config.Digest.Schedule == "" {
+		return fmt.Errorf("notify entry %q uses digest, but no digest schedule is configured", notify.Type)
+	}
+
+	not, err := w.getNotifier(notify.Type)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := not.(digestNotifier); !ok {
+		return fmt.Errorf("notifier %q does not support digests", notify.Type)
+	}
+
+	return nil
+}
+
+// usesDigest reports whether any notify entry uses digests, directly or during a quiet window
+func (w *Watcher) usesDigest() bool {
+	for i := range w.config.Jobs {
+		job := &w.config.Jobs[i]
+		for _, notify := range job.Notify {
+			if notify.Digest {
+				return true
+			}
+
+			windows, _ := w.quietWindows(job, notify)
+			for _, window := range windows {
+				if window.action == windowDigest {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// queueDigest stores a run with changes until the next digest is sent
+func (w *Watcher) queueDigest(job *api.Job, entry api.NotifyEntry, run api.Run, diff []api.Diff, values map[string]string) error {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+
+	var queue []digestItem
+	if err := w.getJSON(digestQueueKey, &queue); err != nil {
+		return fmt.Errorf("failed to load digest queue: %v", err)
+	}
+
+	queue = append(queue, digestItem{
+		Entry: entry,
+		Job:   job.Name,
+		Run: api.DigestRun{
+			Run:    run,
+			Diff:   diff,
+			Values: values,
+		},
+	})
+
+	return w.setJSON(digestQueueKey, queue)
+}
+
+// SendDigests sends all queued runs, one digest per notify entry. Runs of failed digests are queued again.
+func (w *Watcher) SendDigests() error {
+	queue, err := w.popDigests()
+	if err != nil {
+		return err
+	}
+
+	if len(queue) == 0 {
+		return nil
+	}
+
+	var entryKeys []string
+	groups := make(map[string][]digestItem)
+	for _, item := range queue {
+		key, err := recipientKey(item.Entry)
+		if err != nil {
+			w.logger.Errorf("Dropping queued run of job %q with invalid notify entry: %v", item.Job, err)
+			continue
+		}
+
+		if _, ok := groups[key]; !ok {
+			entryKeys = append(entryKeys, key)
+		}
+		groups[key] = append(groups[key], item)
+	}
+
+	var failed []digestItem
+	failedDigests := 0
+	for _, key := range entryKeys {
+		items := groups[key]
+		entry := items[0].Entry
+
+		if err := w.sendDigest(entry, items); err != nil {
+			w.logger.Errorf("failed to send digest by %q: %v", entry.Type, err)
+			failed = append(failed, items...)
+			failedDigests++
+		}
+	}
+
+	w.logger.Infof("Sent %d of %d digests", len(entryKeys)-failedDigests, len(entryKeys))
+
+	if failedDigests == 0 {
+		return nil
+	}
+
+	if err := w.requeueDigests(failed); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("failed to send %d digests", failedDigests)
+}
+
+// popDigests removes all runs from the digest queue, so they are sent without holding the lock
+func (w *Watcher) popDigests() ([]digestItem, error) {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+
+	var queue []digestItem
+	if err := w.getJSON(digestQueueKey, &queue); err != nil {
+		return nil, fmt.Errorf("failed to load digest queue: %v", err)
+	}
+
+	if len(queue) == 0 {
+		return nil, nil
+	}
+
+	return queue, w.storage.Del(digestQueueKey)
+}
+
+// requeueDigests adds runs of digests which failed to be sent to the queue again, before the runs queued meanwhile
+func (w *Watcher) requeueDigests(items []digestItem) error {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+
+	var queue []digestItem
+	if err := w.getJSON(digestQueueKey, &queue); err != nil {
+		return fmt.Errorf("failed to load digest queue: %v", err)
+	}
+
+	if err := w.setJSON(digestQueueKey, append(items, queue...)); err != nil {
+		return fmt.Errorf("failed to store digest queue: %v", err)
+	}
+
+	return nil
+}
+
+// sendDigest renders the queued runs of a notify entry as a single digest, grouped by job
+func (w *Watcher) sendDigest(entry api.NotifyEntry, items []digestItem) error {
+	not, err := w.getNotifier(entry.Type)
+	if err != nil {
+		return err
+	}
+
+	digestNot, ok := not.(digestNotifier)
+	if !ok {
+		return fmt.Errorf("notifier %q does not support digests", entry.Type)
+	}
+
+	digest := api.Digest{
+		Target:    entry.Value,
+		Options:   entry.Options,
+		Templates: w.globalTemplates(entry.Type),
+	}
+
+	jobIndex := make(map[string]int)
+	for _, item := range items {
+		i, ok := jobIndex[item.Job]
+		if !ok {
+			i = len(digest.Jobs)
+			jobIndex[item.Job] = i
+			digest.Jobs = append(digest.Jobs, api.DigestJob{Name: item.Job})
+		}
+		digest.Jobs[i].Runs = append(digest.Jobs[i].Runs, item.Run)
+	}
+
+	return digestNot.NotifyDigest(digest)
+}
+
+// recipientKey identifies the recipients of a notify entry, ignoring its other settings
+func recipientKey(entry api.NotifyEntry) (string, error) {
+	b, err := json.Marshal(api.NotifyEntry{
+		Type:    entry.Type,
+		Value:   entry.Value,
+		Options: entry.Options,
+	})
+
+	return string(b), err
+}
+
+func (w *Watcher) digestCronFunc() func() {
+	return func() {
+		if err := w.SendDigests(); err != nil {
+			w.logger.Error(err)
+		}
+	}
+}
+
+// registerDigest registers sending digests at the given cron instance, if any notify entry uses them
+func (w *Watcher) registerDigest(c *cron.Cron) error {
+	if !w.usesDigest() {
+		return nil
+	}
+
+	w.logger.Debugf("Adding digest with pattern %q", w.config.Digest.Schedule)
+	schedule, err := w.parseSchedule(w.config.Digest.Schedule, "")
+	if err != nil {
+		return fmt.Errorf("failed to register cron for digest: %v", err)
+	}
+	c.Schedule(schedule, cron.FuncJob(w.digestCronFunc()))
+
+	return nil
+}
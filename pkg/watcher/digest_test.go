@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+	"github.com/robfig/cron"
+)
+
+func TestRegisterDigestForQuietWindows(t *testing.T) {
+	job := api.Job{
+		Name:     "job",
+		Schedule: "@every 5m",
+		Notify: []api.NotifyEntry{{
+			Type:         "test",
+			Value:        "user@example.com",
+			QuietWindows: []api.QuietWindow{{From: "00:00", To: "00:00", Action: windowDigest}},
+		}},
+	}
+	w := newTestWatcher(t, newMemStorage(), job)
+	w.config.Digest = &api.DigestConfig{Schedule: "@every 1h"}
+	n := &recordingNotifier{}
+	if err := w.AddNotifier(n); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.CheckConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := cron.New()
+	if err := w.registerDigest(c); err != nil {
+		t.Fatal(err)
+	}
+	entries := c.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("got %d cron entries, want the digest", len(entries))
+	}
+
+	diff := []api.Diff{{Item: "title", OldValue: "old", NewValue: "new", Kind: api.DiffChanged}}
+	if err := w.notify(&w.config.Jobs[0], api.Run{}, diff, map[string]string{"title": "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.notifications) != 0 {
+		t.Fatalf("got %d notifications during quiet window", len(n.notifications))
+	}
+
+	entries[0].Job.Run()
+
+	if len(n.digests) != 1 {
+		t.Fatalf("got %d digests, want 1", len(n.digests))
+	}
+	var queue []digestItem
+	if err := w.getJSON(digestQueueKey, &queue); err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 0 {
+		t.Errorf("got %d queued runs after digest", len(queue))
+	}
+}
@@ -1,24 +1,151 @@
 package watcher
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/Scalify/puppet-master-client-go"
 	"github.com/Scalify/website-content-watcher/pkg/api"
 )
 
-func (w *Watcher) executeJob(job *api.Job) (*puppetmaster.Job, error) {
-	//b, _ := ioutil.ReadFile("job.json")
-	//pmJob := &puppetmaster.Job{}
-	//json.Unmarshal(b, pmJob)
-	//return pmJob, nil
+const (
+	executorSync    = "sync"
+	executorAsync   = "async"
+	executorFixture = "fixture"
+
+	// pmStatusDone is the status of finished puppet-master jobs, successful or not
+	pmStatusDone = "done"
+)
+
+// the interval of polling async jobs starts at minPollInterval and doubles up to maxPollInterval
+var (
+	minPollInterval = time.Second
+	maxPollInterval = 30 * time.Second
+)
+
+// executeJob executes a job with its executor. The returned function is called once the results
+// were processed successfully. Executions by puppet-master wait for a free execution slot, which is
+// held until the request really ends, even if the run is abandoned after its timeout.
+func (w *Watcher) executeJob(ctx context.Context, job *api.Job) (*puppetmaster.Job, func() error, error) {
+	executor := w.executor(job)
+	if executor == executorFixture {
+		return w.executeFixture(job)
+	}
 
 	pmJobReq, err := w.loadJob(job)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	pmJob, err := w.puppet.ExecuteSync(pmJobReq)
+
+	release, err := w.limiter.acquire(ctx, job)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	defer release()
+
+	var pmJob *puppetmaster.Job
+	if executor == executorAsync {
+		pmJob, err = w.executeAsync(ctx, job, pmJobReq)
+	} else {
+		pmJob, err = w.puppet.ExecuteSync(pmJobReq)
+		if err == nil && pmJob == nil {
+			err = fmt.Errorf("failed to execute job: no job returned")
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pmJob, func() error { return nil }, nil
+}
+
+// executeAsync creates a puppet-master job and polls it with backoff until it is done or the context is done.
+// The puppet-master job is deleted afterwards.
+func (w *Watcher) executeAsync(ctx context.Context, job *api.Job, pmJobReq *puppetmaster.JobRequest) (*puppetmaster.Job, error) {
+	pmJob, err := w.puppet.CreateJob(pmJobReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job: %v", err)
+	}
+	if pmJob == nil {
+		return nil, fmt.Errorf("failed to create job: no job returned")
+	}
+
+	uuid := pmJob.UUID
+	defer w.deleteJob(uuid)
+
+	interval := minPollInterval
+	for pmJob.Status != pmStatusDone {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("job %q was not done: %v", uuid, ctx.Err())
+		case <-time.After(interval):
+		}
+
+		if interval *= 2; interval > maxPollInterval {
+			interval = maxPollInterval
+		}
+
+		polled, err := w.puppet.GetJob(uuid)
+		if err == nil && polled == nil {
+			err = fmt.Errorf("no job returned")
+		}
+		if err != nil {
+			w.logger.Warnf("failed to poll job %q of watch job %q: %v", uuid, job.Name, err)
+			continue
+		}
+		pmJob = polled
 	}
 
 	return pmJob, nil
 }
+
+func (w *Watcher) deleteJob(uuid string) {
+	if err := w.puppet.DeleteJob(uuid); err != nil {
+		w.logger.Warnf("failed to delete job %q: %v", uuid, err)
+	}
+}
+
+// executor returns the executor of a job, defaulting to the global one
+func (w *Watcher) executor(job *api.Job) string {
+	if job.Executor != "" {
+		return job.Executor
+	}
+
+	if w.config.Executor != "" {
+		return w.config.Executor
+	}
+
+	return executorSync
+}
+
+// UsesPuppetMaster reports whether any job of a config is executed by puppet-master instead of the fixture executor
+func UsesPuppetMaster(config *api.Config) bool {
+	w := &Watcher{config: config}
+	for i := range config.Jobs {
+		if w.executor(&config.Jobs[i]) != executorFixture {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkExecutor validates the execution settings of a job
+func (w *Watcher) checkExecutor(job *api.Job) error {
+	switch w.executor(job) {
+	case executorSync, executorAsync:
+	case executorFixture:
+		if err := w.checkFixture(job); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown executor %q", w.executor(job))
+	}
+
+	if job.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+
+	return nil
+}
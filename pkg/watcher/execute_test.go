@@ -0,0 +1,142 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Scalify/puppet-master-client-go"
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+// fakePuppetMaster answers polls of a job with the given responses, repeating the last one
+type fakePuppetMaster struct {
+	mu        sync.Mutex
+	createErr error
+	polls     []*puppetmaster.Job
+	pollErrs  []error
+	polled    int
+	deleted   []string
+}
+
+func (f *fakePuppetMaster) CreateJob(jobRequest *puppetmaster.JobRequest) (*puppetmaster.Job, error) {
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+
+	return &puppetmaster.Job{UUID: "uuid", Status: "created"}, nil
+}
+
+func (f *fakePuppetMaster) GetJob(uuid string) (*puppetmaster.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.polled
+	if i >= len(f.polls) {
+		i = len(f.polls) - 1
+	}
+	f.polled++
+
+	return f.polls[i], f.pollErrs[i]
+}
+
+func (f *fakePuppetMaster) DeleteJob(uuid string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.deleted = append(f.deleted, uuid)
+	return nil
+}
+
+func (f *fakePuppetMaster) ExecuteSync(jobRequest *puppetmaster.JobRequest) (*puppetmaster.Job, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestExecuteAsync(t *testing.T) {
+	defer func(min, max time.Duration) {
+		minPollInterval, maxPollInterval = min, max
+	}(minPollInterval, maxPollInterval)
+	minPollInterval, maxPollInterval = 10*time.Millisecond, 40*time.Millisecond
+
+	running := &puppetmaster.Job{UUID: "uuid", Status: "running"}
+	done := &puppetmaster.Job{UUID: "uuid", Status: pmStatusDone}
+
+	tests := []struct {
+		name        string
+		createErr   error
+		polls       []*puppetmaster.Job
+		pollErrs    []error
+		wantErr     bool
+		wantPolls   int
+		wantMinTime time.Duration
+		wantDeleted []string
+	}{
+		{
+			name:        "done",
+			polls:       []*puppetmaster.Job{running, running, done},
+			pollErrs:    []error{nil, nil, nil},
+			wantPolls:   3,
+			wantMinTime: 70 * time.Millisecond,
+			wantDeleted: []string{"uuid"},
+		},
+		{
+			name:        "backoff up to the max interval",
+			polls:       []*puppetmaster.Job{running, running, running, running, done},
+			pollErrs:    []error{nil, nil, nil, nil, nil},
+			wantPolls:   5,
+			wantMinTime: 150 * time.Millisecond,
+			wantDeleted: []string{"uuid"},
+		},
+		{
+			name:        "failed and empty polls retried",
+			polls:       []*puppetmaster.Job{nil, nil, done},
+			pollErrs:    []error{errors.New("bad gateway"), nil, nil},
+			wantPolls:   3,
+			wantDeleted: []string{"uuid"},
+		},
+		{
+			name:        "timeout",
+			polls:       []*puppetmaster.Job{running},
+			pollErrs:    []error{nil},
+			wantErr:     true,
+			wantDeleted: []string{"uuid"},
+		},
+		{
+			name:      "create failed",
+			createErr: errors.New("unauthorized"),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := &fakePuppetMaster{createErr: tt.createErr, polls: tt.polls, pollErrs: tt.pollErrs}
+			w := newTestWatcher(t, newMemStorage(), api.Job{Name: "job"})
+			w.puppet = pm
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			pmJob, err := w.executeAsync(ctx, &w.config.Jobs[0], &puppetmaster.JobRequest{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && pmJob.Status != pmStatusDone {
+				t.Errorf("got job status %q, want %q", pmJob.Status, pmStatusDone)
+			}
+			if tt.wantPolls > 0 && pm.polled != tt.wantPolls {
+				t.Errorf("got %d polls, want %d", pm.polled, tt.wantPolls)
+			}
+			if elapsed := time.Since(start); elapsed < tt.wantMinTime {
+				t.Errorf("got done after %s, want backoff of at least %s", elapsed, tt.wantMinTime)
+			}
+			if !reflect.DeepEqual(pm.deleted, tt.wantDeleted) {
+				t.Errorf("got deleted jobs %v, want %v", pm.deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
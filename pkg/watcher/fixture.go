@@ -0,0 +1,118 @@
+package watcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Scalify/puppet-master-client-go"
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+// fixture returns the directory of the recorded responses of a job and whether they are cycled through
+func (w *Watcher) fixture(job *api.Job) (string, bool, error) {
+	if job.Fixture != nil && job.Fixture.Dir != "" {
+		return w.resolvePath(job.Fixture.Dir), job.Fixture.Cycle, nil
+	}
+
+	if w.config.Fixture != nil && w.config.Fixture.Dir != "" {
+		return filepath.Join(w.resolvePath(w.config.Fixture.Dir), w.cleanJobName(job.Name)), w.config.Fixture.Cycle, nil
+	}
+
+	return "", false, fmt.Errorf("no fixture directory configured")
+}
+
+// fixtureFiles returns the JSON files of a fixture directory in name order
+func fixtureFiles(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture directory: %v", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no JSON files in fixture directory %q", dir)
+	}
+
+	return files, nil
+}
+
+// executeFixture returns the next recorded puppet-master job of a job instead of executing it, and a function
+// advancing to the following one. The position in the sequence is stored, so it continues across restarts.
+// It only advances once the run was processed successfully, so failed runs replay the same response.
+func (w *Watcher) executeFixture(job *api.Job) (*puppetmaster.Job, func() error, error) {
+	dir, cycle, err := w.fixture(job)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files, err := fixtureFiles(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var position int
+	if err := w.getJSON(w.fixtureKey(job.Name), &position); err != nil {
+		return nil, nil, fmt.Errorf("failed to load fixture position: %v", err)
+	}
+
+	if position >= len(files) || position < 0 {
+		position = len(files) - 1
+		if cycle {
+			position = 0
+		}
+	}
+
+	file := files[position]
+	pmJob := &puppetmaster.Job{}
+	if err := w.loadJSONFile(file, pmJob); err != nil {
+		return nil, nil, err
+	}
+
+	if pmJob.UUID == "" {
+		pmJob.UUID = "fixture:" + filepath.Base(file)
+	}
+
+	w.logger.Debugf("Using fixture %q for job %q", file, job.Name)
+	return pmJob, func() error {
+		if err := w.setJSON(w.fixtureKey(job.Name), position+1); err != nil {
+			return fmt.Errorf("failed to store fixture position: %v", err)
+		}
+		return nil
+	}, nil
+}
+
+func (w *Watcher) fixtureKey(jobName string) string {
+	return w.cleanJobName(jobName) + ":fixture"
+}
+
+// checkFixture validates that a job using the fixture executor has recorded responses
+func (w *Watcher) checkFixture(job *api.Job) error {
+	dir, _, err := w.fixture(job)
+	if err != nil {
+		return err
+	}
+
+	files, err := fixtureFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		var pmJob puppetmaster.Job
+		if err := w.loadJSONFile(file, &pmJob); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
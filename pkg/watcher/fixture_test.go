@@ -0,0 +1,98 @@
+package watcher
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestExecuteFixture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"1.json", "2.json"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(`{"results": {}}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	job := api.Job{Name: "job", Executor: executorFixture, Fixture: &api.FixtureConfig{Dir: dir}}
+	w := newTestWatcher(t, newMemStorage(), job)
+
+	next := func() (string, func() error) {
+		t.Helper()
+		pmJob, processed, err := w.executeFixture(&w.config.Jobs[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pmJob.UUID, processed
+	}
+
+	// a failed run doesn't call processed and replays the same response
+	if uuid, _ := next(); uuid != "fixture:1.json" {
+		t.Fatalf("got %q, want first fixture", uuid)
+	}
+	uuid, processed := next()
+	if uuid != "fixture:1.json" {
+		t.Fatalf("got %q after failed run, want first fixture again", uuid)
+	}
+	if err := processed(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		uuid, processed := next()
+		if uuid != "fixture:2.json" {
+			t.Fatalf("got %q, want last fixture to repeat", uuid)
+		}
+		if err := processed(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUsesPuppetMaster(t *testing.T) {
+	tests := []struct {
+		name     string
+		executor string
+		jobs     []api.Job
+		want     bool
+	}{
+		{
+			name: "default executor",
+			jobs: []api.Job{{Name: "a"}},
+			want: true,
+		},
+		{
+			name:     "global fixture executor",
+			executor: executorFixture,
+			jobs:     []api.Job{{Name: "a"}, {Name: "b"}},
+			want:     false,
+		},
+		{
+			name:     "job overriding fixture executor",
+			executor: executorFixture,
+			jobs:     []api.Job{{Name: "a"}, {Name: "b", Executor: executorAsync}},
+			want:     true,
+		},
+		{
+			name: "all jobs using fixtures",
+			jobs: []api.Job{{Name: "a", Executor: executorFixture}},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UsesPuppetMaster(&api.Config{Executor: tt.executor, Jobs: tt.jobs}); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package watcher
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// jobRegistryKey is a sorted set of the keys of all jobs values were stored for, scored by the time they were last stored
+	jobRegistryKey = globalKeyPrefix + "registry:jobs"
+
+	// DefaultGCGrace is how long the state of a removed job is kept unless configured otherwise
+	DefaultGCGrace = 7 * 24 * time.Hour
+)
+
+// RemovedJob is the state of a job removed from the config
+type RemovedJob struct {
+	Key      string
+	LastSeen time.Time
+	Keys     []string
+}
+
+// registerJob marks a job as seen in the job registry
+func (w *Watcher) registerJob(jobName string) error {
+	if err := w.storage.AddMember(jobRegistryKey, w.cleanJobName(jobName), float64(time.Now().Unix())); err != nil {
+		return fmt.Errorf("failed to register job: %v", err)
+	}
+
+	return nil
+}
+
+// GCGrace returns the grace period of state_gc, defaulting to DefaultGCGrace if it isn't set
+func (w *Watcher) GCGrace() time.Duration {
+	if w.config.StateGC == nil || w.config.StateGC.Grace <= 0 {
+		return DefaultGCGrace
+	}
+
+	return time.Duration(w.config.StateGC.Grace)
+}
+
+// CollectGarbage deletes the state of all registered jobs no longer configured and not seen within the grace period.
+// All configured jobs are marked as seen first. With dryRun, nothing is deleted.
+func (w *Watcher) CollectGarbage(grace time.Duration, dryRun bool) ([]RemovedJob, error) {
+	configured := make(map[string]bool)
+	for _, job := range w.config.Jobs {
+		configured[w.cleanJobName(job.Name)] = true
+		if err := w.registerJob(job.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	members, err := w.storage.Members(jobRegistryKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job registry: %v", err)
+	}
+
+	var removed []RemovedJob
+	for key, score := range members {
+		lastSeen := time.Unix(int64(score), 0)
+		if configured[key] || time.Since(lastSeen) < grace {
+			continue
+		}
+
+		keys, err := w.jobKeys(key)
+		if err != nil {
+			return removed, err
+		}
+
+		job := RemovedJob{Key: key, LastSeen: lastSeen, Keys: keys}
+		if !dryRun {
+			if err := w.deleteJobState(job); err != nil {
+				return removed, err
+			}
+			w.logger.Infof("Removed state of job %q last seen at %s: %v", key, lastSeen.Format(time.RFC3339), keys)
+		}
+		removed = append(removed, job)
+	}
+
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i].Key < removed[j].Key
+	})
+
+	return removed, nil
+}
+
+// jobKeys returns all storage keys of a job key, e.g. its values, pending values and history
+func (w *Watcher) jobKeys(key string) ([]string, error) {
+	keys, err := w.storage.Keys(key + ":*")
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	return append([]string{key}, keys...), nil
+}
+
+func (w *Watcher) deleteJobState(job RemovedJob) error {
+	for _, key := range job.Keys {
+		if err := w.storage.Del(key); err != nil {
+			return fmt.Errorf("failed to delete state of job %q: %v", job.Key, err)
+		}
+	}
+
+	if err := w.storage.RemoveMember(jobRegistryKey, job.Key); err != nil {
+		return fmt.Errorf("failed to unregister job %q: %v", job.Key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package watcher
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	longAgo := float64(time.Now().Add(-48 * time.Hour).Unix())
+	recently := float64(time.Now().Add(-time.Hour).Unix())
+
+	tests := []struct {
+		name        string
+		lastSeen    map[string]float64
+		dryRun      bool
+		wantRemoved []string
+		wantKeys    []string
+	}{
+		{
+			name:        "removes jobs not seen within the grace period",
+			lastSeen:    map[string]float64{"old": longAgo},
+			wantRemoved: []string{"old"},
+			wantKeys:    []string{"_delayed:queue", "_digest:queue", "_registry:jobs", "kept", "kept:pending", "recent", "recent:pending"},
+		},
+		{
+			name:        "keeps jobs seen recently",
+			lastSeen:    map[string]float64{"old": longAgo, "recent": recently},
+			wantRemoved: []string{"old"},
+			wantKeys:    []string{"_delayed:queue", "_digest:queue", "_registry:jobs", "kept", "kept:pending", "recent", "recent:pending"},
+		},
+		{
+			name:        "keeps configured jobs",
+			lastSeen:    map[string]float64{"kept": longAgo},
+			wantRemoved: nil,
+			wantKeys:    []string{"_delayed:queue", "_digest:queue", "_registry:jobs", "kept", "kept:pending", "old", "old:history:price", "old:pending", "recent", "recent:pending"},
+		},
+		{
+			name:        "deletes nothing on a dry run",
+			lastSeen:    map[string]float64{"old": longAgo},
+			dryRun:      true,
+			wantRemoved: []string{"old"},
+			wantKeys:    []string{"_delayed:queue", "_digest:queue", "_registry:jobs", "kept", "kept:pending", "old", "old:history:price", "old:pending", "recent", "recent:pending"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemStorage()
+			for _, key := range []string{"old", "old:pending", "old:history:price", "recent", "recent:pending", "kept", "kept:pending", digestQueueKey, delayedQueueKey} {
+				store.values[key] = "{}"
+			}
+			for key, score := range tt.lastSeen {
+				_ = store.AddMember(jobRegistryKey, key, score)
+			}
+
+			w := newTestWatcher(t, store, api.Job{Name: "kept"})
+			removed, err := w.CollectGarbage(24*time.Hour, tt.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, job := range removed {
+				names = append(names, job.Key)
+			}
+			if !reflect.DeepEqual(names, tt.wantRemoved) {
+				t.Errorf("removed %v, want %v", names, tt.wantRemoved)
+			}
+
+			if keys := store.keys(); !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("kept keys %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestCollectGarbageKeepsGlobalKeys(t *testing.T) {
+	for _, name := range []string{"digest", "delayed", "registry"} {
+		t.Run(name, func(t *testing.T) {
+			store := newMemStorage()
+			store.values[name] = "{}"
+			store.values[digestQueueKey] = "[]"
+			store.values[delayedQueueKey] = "[]"
+			_ = store.AddMember(jobRegistryKey, name, float64(time.Now().Add(-48*time.Hour).Unix()))
+
+			w := newTestWatcher(t, store)
+			removed, err := w.CollectGarbage(24*time.Hour, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(removed) != 1 || !reflect.DeepEqual(removed[0].Keys, []string{name}) {
+				t.Errorf("removed %+v, want only key %q", removed, name)
+			}
+
+			want := []string{delayedQueueKey, digestQueueKey}
+			if keys := store.keys(); !reflect.DeepEqual(keys, want) {
+				t.Errorf("kept keys %v, want %v", keys, want)
+			}
+		})
+	}
+}
+
+func TestJobKeys(t *testing.T) {
+	store := newMemStorage()
+	for _, key := range []string{"price", "price:pending", "price:history:a", "pricelist", "pricelist:pending", digestQueueKey} {
+		store.values[key] = "{}"
+	}
+
+	keys, err := newTestWatcher(t, store).jobKeys("price")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"price", "price:history:a", "price:pending"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %v, want %v", keys, want)
+	}
+}
+
+func TestGCGrace(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *api.StateGCConfig
+		want time.Duration
+	}{
+		{name: "not configured", want: DefaultGCGrace},
+		{name: "empty", cfg: &api.StateGCConfig{}, want: DefaultGCGrace},
+		{name: "configured", cfg: &api.StateGCConfig{Grace: api.Duration(time.Hour)}, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWatcher(t, newMemStorage())
+			w.config.StateGC = tt.cfg
+
+			if got := w.GCGrace(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
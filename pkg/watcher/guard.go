@@ -0,0 +1,167 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+// suspectState tracks consecutive runs of a job with identical suspected results
+type suspectState struct {
+	Hash string `json:"hash"`
+	Runs int    `json:"runs"`
+}
+
+// checkGuards validates the guards of a job
+func checkGuards(job *api.Job) error {
+	if job.Guards == nil {
+		return nil
+	}
+
+	if job.Guards.MinItems < 0 {
+		return fmt.Errorf("min_items must not be negative")
+	}
+
+	if job.Guards.MaxChangedRatio < 0 || job.Guards.MaxChangedRatio > 1 {
+		return fmt.Errorf("max_changed_ratio must be between 0 and 1")
+	}
+
+	if job.Guards.AcceptAfter < 0 {
+		return fmt.Errorf("accept_after must not be negative")
+	}
+
+	return nil
+}
+
+// guardValues checks the results of a run, before they are compared. Suspected results are handled by suspect.
+func guardValues(job *api.Job, values map[string]string) error {
+	if job.Guards == nil {
+		return nil
+	}
+
+	if len(values) < job.Guards.MinItems {
+		return fmt.Errorf("got %d items, expected at least %d", len(values), job.Guards.MinItems)
+	}
+
+	var missing []string
+	for _, item := range job.Guards.RequiredItems {
+		if values[item] == "" {
+			missing = append(missing, item)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("required items %q are missing or empty", missing)
+	}
+
+	return nil
+}
+
+// guardDiff checks the share of items changed by a run, leaving out ignored items. Runs without stored values
+// are not checked. Suspected results are handled by suspect.
+func guardDiff(job *api.Job, diff []api.Diff, newValues, oldValues map[string]string) error {
+	if job.Guards == nil || job.Guards.MaxChangedRatio == 0 || len(oldValues) == 0 {
+		return nil
+	}
+
+	ignored, err := newItemMatcher(job.IgnoreItems)
+	if err != nil {
+		return err
+	}
+
+	var total int
+	var removed []string
+	for item := range oldValues {
+		if ignored(item) {
+			continue
+		}
+
+		total++
+		if _, ok := newValues[item]; !ok {
+			removed = append(removed, item)
+		}
+	}
+	sort.Strings(removed)
+
+	// the diff holds no ignored items
+	changed := len(removed)
+	for _, d := range diff {
+		switch d.Kind {
+		case api.DiffAdded:
+			total++
+			changed++
+		case api.DiffChanged:
+			changed++
+		}
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	ratio := float64(changed) / float64(total)
+	if ratio > job.Guards.MaxChangedRatio {
+		return fmt.Errorf("%d of %d items changed, %d of them removed %q, at most %.0f%% are allowed",
+			changed, total, len(removed), removed, job.Guards.MaxChangedRatio*100)
+	}
+
+	return nil
+}
+
+// suspect fails a run with results suspected to be broken by err. Identical results of consecutive runs
+// are only reported once and accepted after job.Guards.AcceptAfter runs, if set, returning nil.
+func (w *Watcher) suspect(job *api.Job, values map[string]string, err error) error {
+	b, jsonErr := json.Marshal(values)
+	if jsonErr != nil {
+		return fmt.Errorf("failed to hash suspected results: %v", jsonErr)
+	}
+	sum := sha256.Sum256(b)
+	hash := hex.EncodeToString(sum[:])
+
+	var state suspectState
+	if getErr := w.getJSON(w.suspectKey(job.Name), &state); getErr != nil {
+		return fmt.Errorf("failed to load suspected results: %v", getErr)
+	}
+
+	if state.Hash == hash {
+		state.Runs++
+	} else {
+		state = suspectState{Hash: hash, Runs: 1}
+	}
+
+	if job.Guards.AcceptAfter > 0 && state.Runs >= job.Guards.AcceptAfter {
+		w.logger.Warnf("Accepting results of job %q suspected on %d consecutive runs: %v", job.Name, state.Runs, err)
+		return nil
+	}
+
+	if setErr := w.setJSON(w.suspectKey(job.Name), state); setErr != nil {
+		return fmt.Errorf("failed to store suspected results: %v", setErr)
+	}
+
+	return &runError{
+		kind:     api.FailureSuspect,
+		repeated: state.Runs > 1,
+		err:      fmt.Errorf("suspected broken results of job %q, keeping previous values: %v", job.Name, err),
+	}
+}
+
+// clearSuspect forgets the suspected results of a job after a run got stored
+func (w *Watcher) clearSuspect(job *api.Job) error {
+	if job.Guards == nil {
+		return nil
+	}
+
+	if err := w.storage.Del(w.suspectKey(job.Name)); err != nil {
+		return fmt.Errorf("failed to delete suspected results: %v", err)
+	}
+
+	return nil
+}
+
+func (w *Watcher) suspectKey(jobName string) string {
+	return w.cleanJobName(jobName) + ":suspect"
+}
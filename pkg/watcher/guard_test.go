@@ -0,0 +1,139 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestGuardDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		ignore    []string
+		diff      []api.Diff
+		newValues map[string]string
+		oldValues map[string]string
+		wantErr   bool
+	}{
+		{
+			name:      "below ratio",
+			diff:      []api.Diff{{Item: "a", Kind: api.DiffChanged}},
+			newValues: map[string]string{"a": "1", "b": "2", "c": "3"},
+			oldValues: map[string]string{"a": "0", "b": "2", "c": "3"},
+		},
+		{
+			name:      "removed items above ratio",
+			newValues: map[string]string{"a": "1"},
+			oldValues: map[string]string{"a": "1", "b": "2", "c": "3"},
+			wantErr:   true,
+		},
+		{
+			name:      "removed ignored items",
+			ignore:    []string{"ad*"},
+			newValues: map[string]string{"a": "1"},
+			oldValues: map[string]string{"a": "1", "ad1": "2", "ad2": "3"},
+		},
+		{
+			name:      "only ignored items",
+			ignore:    []string{"*"},
+			newValues: map[string]string{},
+			oldValues: map[string]string{"a": "1"},
+		},
+		{
+			name:      "no stored values",
+			diff:      []api.Diff{{Item: "a", Kind: api.DiffAdded}},
+			newValues: map[string]string{"a": "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &api.Job{
+				IgnoreItems: tt.ignore,
+				Guards:      &api.GuardConfig{MaxChangedRatio: 0.5},
+			}
+
+			err := guardDiff(job, tt.diff, tt.newValues, tt.oldValues)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessSuspect(t *testing.T) {
+	job := api.Job{Name: "job", Guards: &api.GuardConfig{MinItems: 2, AcceptAfter: 3}}
+	suspected := map[string]interface{}{"title": "broken"}
+
+	tests := []struct {
+		name         string
+		results      map[string]interface{}
+		wantKind     api.FailureKind
+		wantRepeated bool
+		wantStored   string
+	}{
+		{name: "first suspect run", results: suspected, wantKind: api.FailureSuspect, wantStored: "old"},
+		{name: "repeated suspect run", results: suspected, wantKind: api.FailureSuspect, wantRepeated: true, wantStored: "old"},
+		{name: "different suspect run", results: map[string]interface{}{"title": "other"}, wantKind: api.FailureSuspect, wantStored: "old"},
+		{name: "second identical run", results: map[string]interface{}{"title": "other"}, wantKind: api.FailureSuspect, wantRepeated: true, wantStored: "old"},
+		{name: "accepted after three runs", results: map[string]interface{}{"title": "other"}, wantStored: "other"},
+		{name: "suspect again after accepting", results: suspected, wantKind: api.FailureSuspect, wantStored: "other"},
+	}
+
+	store := newMemStorage()
+	w := newTestWatcher(t, store, job)
+	if err := w.setValues(job.Name, map[string]string{"title": "old", "price": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := w.process(context.Background(), &w.config.Jobs[0], api.Run{}, tt.results)
+			if tt.wantKind == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if kind := failureKind(err); kind != tt.wantKind {
+				t.Fatalf("got failure %q, want %q: %v", kind, tt.wantKind, err)
+			}
+
+			if got := repeatedFailure(err); got != tt.wantRepeated {
+				t.Errorf("got repeated %v, want %v", got, tt.wantRepeated)
+			}
+
+			values, err := w.State(job.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if values["title"] != tt.wantStored {
+				t.Errorf("got stored title %q, want %q", values["title"], tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestNotifiesFailure(t *testing.T) {
+	tests := []struct {
+		name            string
+		notifyOnFailure bool
+		err             error
+		want            bool
+	}{
+		{name: "failure", err: &runError{kind: api.FailureExecution}},
+		{name: "failure with notify_on_failure", notifyOnFailure: true, err: &runError{kind: api.FailureExecution}, want: true},
+		{name: "suspect", err: &runError{kind: api.FailureSuspect}, want: true},
+		{name: "repeated suspect", err: &runError{kind: api.FailureSuspect, repeated: true}},
+		{name: "other error with notify_on_failure", notifyOnFailure: true, err: errors.New("failed"), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &api.Job{NotifyOnFailure: tt.notifyOnFailure}
+			if got := notifiesFailure(job, tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+	"github.com/Scalify/website-content-watcher/pkg/text"
+)
+
+const (
+	compareByValue = "value"
+	compareByHash  = "hash"
+
+	defaultPreviewLength     = 100
+	defaultHistoryMaxEntries = 10
+)
+
+// historyEntry is a full value of an item compared by hash
+type historyEntry struct {
+	Time  time.Time `json:"time"`
+	Hash  string    `json:"hash"`
+	Value string    `json:"value"`
+}
+
+// checkCompare validates the compare settings of all configured items
+func checkCompare(job *api.Job) error {
+	for item, cfg := range job.Items {
+		if cfg.Compare != "" && cfg.Compare != compareByValue && cfg.Compare != compareByHash {
+			return fmt.Errorf("item %q: unknown compare mode %q", item, cfg.Compare)
+		}
+
+		if cfg.PreviewLength < 0 {
+			return fmt.Errorf("item %q: preview_length must not be negative", item)
+		}
+	}
+
+	if job.History != nil && (job.History.MaxEntries < 0 || job.History.MaxValueSize < 0) {
+		return fmt.Errorf("history limits must not be negative")
+	}
+
+	return nil
+}
+
+// itemConfig returns the configuration of an item, falling back to the one of all items
+func itemConfig(job *api.Job, item string) api.ItemConfig {
+	cfg := job.Items[item]
+	all := job.Items[allItems]
+
+	if cfg.Compare == "" {
+		cfg.Compare = all.Compare
+	}
+	if cfg.PreviewLength == 0 {
+		cfg.PreviewLength = all.PreviewLength
+	}
+	if cfg.PreviewLength == 0 {
+		cfg.PreviewLength = defaultPreviewLength
+	}
+	if cfg.Type == "" {
+		cfg.Type = all.Type
+	}
+	if cfg.Pattern == "" {
+		cfg.Pattern = all.Pattern
+	}
+	if cfg.Locale == "" {
+		cfg.Locale = all.Locale
+	}
+
+	return cfg
+}
+
+// hashValues replaces the values of items compared by hash with a preview and the hash of
+// their normalized value. It also returns the hashes by replaced value, as they are never parsed back.
+func (w *Watcher) hashValues(job *api.Job, values map[string]string) (map[string]string, map[string]string, error) {
+	res := make(map[string]string, len(values))
+	hashes := make(map[string]string)
+
+	for key, value := range values {
+		cfg := itemConfig(job, key)
+		if cfg.Compare != compareByHash {
+			res[key] = value
+			continue
+		}
+
+		hash, err := w.hashValue(job, key, value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		res[key] = fmt.Sprintf("%s (sha256:%s)", text.Truncate(cfg.PreviewLength, value), hash)
+		hashes[res[key]] = hash
+	}
+
+	return res, hashes, nil
+}
+
+// comparesByHash reports whether any item of a job is compared by hash
+func comparesByHash(job *api.Job) bool {
+	for _, cfg := range job.Items {
+		if cfg.Compare == compareByHash {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hashValue returns the SHA-256 of the normalized value of an item
+func (w *Watcher) hashValue(job *api.Job, item, value string) (string, error) {
+	normalized, err := w.normalizeValue(job, item, value)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// compareValues returns the values used to detect changes, taking the hashes of hashed values from hashes
+func (w *Watcher) compareValues(job *api.Job, values, hashes map[string]string) (map[string]string, error) {
+	res := make(map[string]string, len(values))
+	for key, value := range values {
+		compare, err := w.compareValue(job, key, value, hashes)
+		if err != nil {
+			return nil, err
+		}
+		res[key] = compare
+	}
+
+	return res, nil
+}
+
+// compareValue returns the value used to detect changes of an item:
+// the hash for items compared by hash, the normalized value otherwise.
+func (w *Watcher) compareValue(job *api.Job, item, value string, hashes map[string]string) (string, error) {
+	if itemConfig(job, item).Compare != compareByHash {
+		return w.normalizeValue(job, item, value)
+	}
+
+	// values stored before switching to compare by hash are hashed on the fly
+	if hash, ok := hashes[value]; ok {
+		return hash, nil
+	}
+
+	return w.hashValue(job, item, value)
+}
+
+// mergeHashes returns the hashes of both runs
+func mergeHashes(oldHashes, newHashes map[string]string) map[string]string {
+	res := make(map[string]string, len(oldHashes)+len(newHashes))
+	for value, hash := range oldHashes {
+		res[value] = hash
+	}
+	for value, hash := range newHashes {
+		res[value] = hash
+	}
+
+	return res
+}
+
+// writeHistory adds the full values of all stored items compared by hash to their history, if enabled.
+// newHashes are the hashes of the run, so values held back by confirmation are left out.
+func (w *Watcher) writeHistory(job *api.Job, values, stored, newHashes map[string]string) error {
+	if job.History == nil {
+		return nil
+	}
+
+	for key, value := range values {
+		hash, ok := newHashes[stored[key]]
+		if !ok {
+			continue
+		}
+
+		if err := w.addHistory(job, key, hash, value); err != nil {
+			return fmt.Errorf("failed to write history of item %q: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+// addHistory appends a full value to the history of an item, enforcing the configured limits
+func (w *Watcher) addHistory(job *api.Job, item, hash, value string) error {
+	if job.History == nil {
+		return nil
+	}
+
+	if job.History.MaxValueSize > 0 && len(value) > job.History.MaxValueSize {
+		w.logger.Warnf("Not writing value of item %q in job %q to history: size %d exceeds limit of %d bytes", item, job.Name, len(value), job.History.MaxValueSize)
+		return nil
+	}
+
+	key := w.historyKey(job.Name, item)
+	var history []historyEntry
+	if err := w.getJSON(key, &history); err != nil {
+		return err
+	}
+
+	if len(history) > 0 && history[len(history)-1].Hash == hash {
+		return nil
+	}
+
+	history = append(history, historyEntry{Time: time.Now(), Hash: hash, Value: value})
+
+	maxEntries := job.History.MaxEntries
+	if maxEntries == 0 {
+		maxEntries = defaultHistoryMaxEntries
+	}
+	if len(history) > maxEntries {
+		history = history[len(history)-maxEntries:]
+	}
+
+	return w.setJSON(key, history)
+}
+
+func (w *Watcher) historyKey(jobName, item string) string {
+	return w.cleanJobName(jobName) + ":history:" + item
+}
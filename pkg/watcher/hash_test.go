@@ -0,0 +1,117 @@
+package watcher
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestProcessCompareByHash(t *testing.T) {
+	// a raw value looking like a stored hash must not be mistaken for one
+	lookalike := "terms (sha256:" + "0000000000000000000000000000000000000000000000000000000000000000" + ")"
+
+	tests := []struct {
+		name        string
+		stored      map[string]string
+		results     []string
+		wantChanges []int
+		wantHistory int
+	}{
+		{
+			name:        "unchanged value",
+			results:     []string{"terms v1", "terms v1"},
+			wantChanges: []int{1, 0},
+			wantHistory: 1,
+		},
+		{
+			name:        "changed value",
+			results:     []string{"terms v1", "terms v2"},
+			wantChanges: []int{1, 1},
+			wantHistory: 2,
+		},
+		{
+			name:        "value stored before comparing by hash",
+			stored:      map[string]string{"body": "terms v1"},
+			results:     []string{"terms v1"},
+			wantChanges: []int{0},
+			wantHistory: 1,
+		},
+		{
+			name:        "value stored before comparing by hash looking like a hash",
+			stored:      map[string]string{"body": lookalike},
+			results:     []string{lookalike},
+			wantChanges: []int{0},
+			wantHistory: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := api.Job{
+				Name:    "job",
+				Items:   map[string]api.ItemConfig{"body": {Compare: compareByHash}},
+				History: &api.HistoryConfig{},
+				Notify:  []api.NotifyEntry{{Type: "test"}},
+			}
+			store := newMemStorage()
+			w := newTestWatcher(t, store, job)
+			n := &recordingNotifier{}
+			if err := w.AddNotifier(n); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.stored != nil {
+				if err := w.setValues(job.Name, tt.stored); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var changes []int
+			for _, result := range tt.results {
+				before := len(n.notifications)
+				if err := w.process(context.Background(), &w.config.Jobs[0], api.Run{}, map[string]interface{}{"body": result}); err != nil {
+					t.Fatal(err)
+				}
+
+				count := 0
+				for _, notification := range n.notifications[before:] {
+					count += len(notification.Diff)
+				}
+				changes = append(changes, count)
+			}
+
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("got changes per run %v, want %v", changes, tt.wantChanges)
+			}
+
+			var history []historyEntry
+			if err := w.getJSON(w.historyKey(job.Name, "body"), &history); err != nil {
+				t.Fatal(err)
+			}
+			if len(history) != tt.wantHistory {
+				t.Errorf("got %d history entries, want %d", len(history), tt.wantHistory)
+			}
+		})
+	}
+}
+
+func TestProcessHistoryOnlyOfStoredValues(t *testing.T) {
+	job := api.Job{
+		Name:    "job",
+		Items:   map[string]api.ItemConfig{"body": {Compare: compareByHash}, "title": {Type: "number"}},
+		History: &api.HistoryConfig{},
+	}
+	store := newMemStorage()
+	w := newTestWatcher(t, store, job)
+
+	results := map[string]interface{}{"body": "terms", "title": "not a number"}
+	if err := w.process(context.Background(), &w.config.Jobs[0], api.Run{}, results); err == nil {
+		t.Fatal("expected invalid results to fail")
+	}
+
+	if keys := store.keys(); len(keys) != 0 {
+		t.Errorf("got stored keys %v after failed run, want none", keys)
+	}
+}
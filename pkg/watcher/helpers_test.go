@@ -0,0 +1,162 @@
+package watcher
+
+import (
+	"path"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+	"github.com/Scalify/website-content-watcher/pkg/storage"
+	"github.com/Sirupsen/logrus"
+)
+
+// memStorage is an in-memory storage behaving like the redis storage
+type memStorage struct {
+	mu     sync.Mutex
+	values map[string]string
+	sets   map[string]map[string]float64
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{
+		values: make(map[string]string),
+		sets:   make(map[string]map[string]float64),
+	}
+}
+
+func (m *memStorage) Get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	value, ok := m.values[key]
+	if !ok {
+		return "", storage.ErrNotFound
+	}
+
+	return value, nil
+}
+
+func (m *memStorage) Set(key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.values[key] = value
+	return nil
+}
+
+func (m *memStorage) Del(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.values, key)
+	delete(m.sets, key)
+	return nil
+}
+
+func (m *memStorage) AddMember(key, member string, score float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sets[key] == nil {
+		m.sets[key] = make(map[string]float64)
+	}
+	m.sets[key][member] = score
+	return nil
+}
+
+func (m *memStorage) Members(key string) (map[string]float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members := make(map[string]float64)
+	for member, score := range m.sets[key] {
+		members[member] = score
+	}
+
+	return members, nil
+}
+
+func (m *memStorage) RemoveMember(key, member string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// like redis, empty sets are deleted
+	delete(m.sets[key], member)
+	if len(m.sets[key]) == 0 {
+		delete(m.sets, key)
+	}
+	return nil
+}
+
+func (m *memStorage) Keys(pattern string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []string
+	for key := range m.values {
+		if ok, _ := path.Match(pattern, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	for key := range m.sets {
+		if ok, _ := path.Match(pattern, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// keys returns all stored keys in order
+func (m *memStorage) keys() []string {
+	keys, _ := m.Keys("*")
+	return keys
+}
+
+// newTestWatcher returns a watcher of the given jobs using an in-memory storage
+func newTestWatcher(t *testing.T, store storageClient, jobs ...api.Job) *Watcher {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	return New(logrus.NewEntry(logger), store, nil, "/config/config.yaml", &api.Config{Jobs: jobs})
+}
+
+// recordingNotifier records all notifications and failures it is sent
+type recordingNotifier struct {
+	mu            sync.Mutex
+	notifications []api.Notification
+	failures      []api.Failure
+	digests       []api.Digest
+}
+
+func (r *recordingNotifier) Key() string {
+	return "test"
+}
+
+func (r *recordingNotifier) Notify(notification api.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.notifications = append(r.notifications, notification)
+	return nil
+}
+
+func (r *recordingNotifier) NotifyFailure(failure api.Failure) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.failures = append(r.failures, failure)
+	return nil
+}
+
+func (r *recordingNotifier) NotifyDigest(digest api.Digest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.digests = append(r.digests, digest)
+	return nil
+}
@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+// itemMatcher reports whether an item name matches
+type itemMatcher func(item string) bool
+
+// newItemMatcher creates a matcher for the given patterns. A pattern is either a regular expression
+// wrapped in slashes or a glob pattern, which also covers exact names.
+func newItemMatcher(patterns []string) (itemMatcher, error) {
+	var regExps []*regexp.Regexp
+	var globs []string
+
+	for _, p := range patterns {
+		if len(p) > 1 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+			re, err := regexp.Compile(p[1 : len(p)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %v", p, err)
+			}
+			regExps = append(regExps, re)
+			continue
+		}
+
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", p, err)
+		}
+		globs = append(globs, p)
+	}
+
+	return func(item string) bool {
+		for _, g := range globs {
+			if ok, _ := path.Match(g, item); ok {
+				return true
+			}
+		}
+
+		for _, re := range regExps {
+			if re.MatchString(item) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// removeIgnored drops all diff entries of items ignored by the job
+func removeIgnored(job *api.Job, diff []api.Diff) ([]api.Diff, error) {
+	if len(job.IgnoreItems) == 0 {
+		return diff, nil
+	}
+
+	ignored, err := newItemMatcher(job.IgnoreItems)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]api.Diff, 0, len(diff))
+	for _, d := range diff {
+		if !ignored(d.Item) {
+			res = append(res, d)
+		}
+	}
+
+	return res, nil
+}
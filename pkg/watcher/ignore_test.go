@@ -0,0 +1,101 @@
+package watcher
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestItemMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		match    []string
+		noMatch  []string
+		wantErr  bool
+	}{
+		{
+			name:     "exact name",
+			patterns: []string{"price"},
+			match:    []string{"price"},
+			noMatch:  []string{"prices", "old price"},
+		},
+		{
+			name:     "glob",
+			patterns: []string{"ad_*", "banner?"},
+			match:    []string{"ad_", "ad_top", "banner1"},
+			noMatch:  []string{"top_ad_1", "banner12"},
+		},
+		{
+			name:     "regular expression",
+			patterns: []string{`/^ad_\d+$/`},
+			match:    []string{"ad_1", "ad_42"},
+			noMatch:  []string{"ad_top", "/^ad_\\d+$/"},
+		},
+		{
+			name:     "unanchored regular expression",
+			patterns: []string{"/time/"},
+			match:    []string{"time", "updated time", "timestamp"},
+			noMatch:  []string{"date"},
+		},
+		{
+			name:     "slash is a glob",
+			patterns: []string{"/"},
+			match:    []string{"/"},
+			noMatch:  []string{"a"},
+		},
+		{
+			name:    "no patterns",
+			noMatch: []string{"", "price"},
+		},
+		{
+			name:     "invalid regular expression",
+			patterns: []string{"/(/"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid glob",
+			patterns: []string{"[a"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := newItemMatcher(tt.patterns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			for _, item := range tt.match {
+				if !matches(item) {
+					t.Errorf("got %q not matched", item)
+				}
+			}
+			for _, item := range tt.noMatch {
+				if matches(item) {
+					t.Errorf("got %q matched", item)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveIgnored(t *testing.T) {
+	job := &api.Job{IgnoreItems: []string{"ad_*", "/^time/"}}
+	diff := []api.Diff{{Item: "ad_top"}, {Item: "price"}, {Item: "timestamp"}, {Item: "title"}}
+
+	got, err := removeIgnored(job, diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []api.Diff{{Item: "price"}, {Item: "title"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
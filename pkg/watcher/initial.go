@@ -0,0 +1,40 @@
+package watcher
+
+import (
+	"fmt"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+const (
+	initialRunNotify  = "notify"
+	initialRunSilent  = "silent"
+	initialRunSummary = "summary"
+)
+
+// checkInitialRun validates the initial run mode of a job
+func checkInitialRun(job *api.Job) error {
+	switch job.InitialRun {
+	case "", initialRunNotify, initialRunSilent, initialRunSummary:
+		return nil
+	}
+
+	return fmt.Errorf("unknown initial_run mode %q", job.InitialRun)
+}
+
+// baselineOnly reports whether a run only establishes the baseline of a job without reporting changes.
+// Only the first run does, when no values were stored yet; stored empty values are a baseline already.
+func baselineOnly(job *api.Job, firstRun bool) bool {
+	return firstRun && job.InitialRun != "" && job.InitialRun != initialRunNotify
+}
+
+// notifyInitial handles the first run of a job establishing its baseline. In summary mode, all notify entries
+// not using digests get a one-time notification about the watched items, respecting their quiet windows.
+func (w *Watcher) notifyInitial(job *api.Job, run api.Run, values map[string]string) error {
+	if job.InitialRun != initialRunSummary {
+		w.logger.Infof("Storing baseline of job %q with %d items without notifying", job.Name, len(values))
+		return nil
+	}
+
+	return w.dispatch(job, run, nil, values, true)
+}
@@ -0,0 +1,157 @@
+package watcher
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestProcessInitialRun(t *testing.T) {
+	results := map[string]interface{}{"title": "new"}
+
+	tests := []struct {
+		name       string
+		initialRun string
+		stored     map[string]string
+		wantNotify bool
+		wantDiff   []string
+	}{
+		{
+			name:       "silent first run",
+			initialRun: initialRunSilent,
+			wantNotify: false,
+		},
+		{
+			name:       "silent run after empty state",
+			initialRun: initialRunSilent,
+			stored:     map[string]string{},
+			wantNotify: true,
+			wantDiff:   []string{"title added"},
+		},
+		{
+			name:       "notifying first run",
+			initialRun: initialRunNotify,
+			wantNotify: true,
+			wantDiff:   []string{"title added"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := api.Job{
+				Name:       "job",
+				InitialRun: tt.initialRun,
+				Notify:     []api.NotifyEntry{{Type: "test"}},
+			}
+			w := newTestWatcher(t, newMemStorage(), job)
+			n := &recordingNotifier{}
+			if err := w.AddNotifier(n); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.stored != nil {
+				if err := w.setValues(job.Name, tt.stored); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := w.process(context.Background(), &w.config.Jobs[0], api.Run{}, results); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := len(n.notifications) > 0; got != tt.wantNotify {
+				t.Fatalf("got notified %v, want %v", got, tt.wantNotify)
+			}
+			if tt.wantNotify {
+				var diff []string
+				for _, d := range n.notifications[0].Diff {
+					diff = append(diff, d.Item+" "+string(d.Kind))
+				}
+				if !reflect.DeepEqual(diff, tt.wantDiff) {
+					t.Errorf("got diff %v, want %v", diff, tt.wantDiff)
+				}
+			}
+
+			values, err := w.State(job.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(values, map[string]string{"title": "new"}) {
+				t.Errorf("got stored values %v", values)
+			}
+		})
+	}
+}
+
+func TestInitialSummaryDispatch(t *testing.T) {
+	always := func(action string) []api.QuietWindow {
+		return []api.QuietWindow{{From: "00:00", To: "00:00", Action: action}}
+	}
+
+	tests := []struct {
+		name        string
+		entry       api.NotifyEntry
+		wantNotify  bool
+		wantDelayed bool
+	}{
+		{name: "no quiet window", entry: api.NotifyEntry{Type: "test"}, wantNotify: true},
+		{name: "dropped in quiet window", entry: api.NotifyEntry{Type: "test", QuietWindows: always(windowDrop)}},
+		{name: "delayed by quiet window", entry: api.NotifyEntry{Type: "test", QuietWindows: always(windowDelay)}, wantDelayed: true},
+		{name: "digest window", entry: api.NotifyEntry{Type: "test", QuietWindows: always(windowDigest)}},
+		{name: "digest entry", entry: api.NotifyEntry{Type: "test", Digest: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := api.Job{Name: "job", InitialRun: initialRunSummary, Notify: []api.NotifyEntry{tt.entry}}
+			w := newTestWatcher(t, newMemStorage(), job)
+			n := &recordingNotifier{}
+			if err := w.AddNotifier(n); err != nil {
+				t.Fatal(err)
+			}
+
+			results := map[string]interface{}{"title": "new"}
+			if err := w.process(context.Background(), &w.config.Jobs[0], api.Run{}, results); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := len(n.notifications) == 1 && n.notifications[0].Initial; got != tt.wantNotify || len(n.notifications) > 1 {
+				t.Fatalf("got %d notifications, want initial summary %v", len(n.notifications), tt.wantNotify)
+			}
+
+			var digests []digestItem
+			if err := w.getJSON(digestQueueKey, &digests); err != nil {
+				t.Fatal(err)
+			}
+			if len(digests) > 0 {
+				t.Errorf("got %d runs queued for digests", len(digests))
+			}
+
+			var delayed []delayedItem
+			if err := w.getJSON(delayedQueueKey, &delayed); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(delayed) == 1; got != tt.wantDelayed {
+				t.Fatalf("got %d delayed notifications, want delayed %v", len(delayed), tt.wantDelayed)
+			}
+			if !tt.wantDelayed {
+				return
+			}
+
+			// the window ends
+			delayed[0].Until = time.Now().Add(-time.Minute)
+			if err := w.setJSON(delayedQueueKey, delayed); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.SendDelayed(); err != nil {
+				t.Fatal(err)
+			}
+			if len(n.notifications) != 1 || !n.notifications[0].Initial {
+				t.Errorf("got %d notifications after the window, want the initial summary", len(n.notifications))
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package watcher
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+const (
+	typeString   = "string"
+	typeNumber   = "number"
+	typeBoolean  = "boolean"
+	typeURL      = "url"
+	typeCurrency = "currency"
+
+	onInvalidReject = "reject"
+	onInvalidFlag   = "flag"
+)
+
+var currencySymbols = map[string]string{
+	"€":   "EUR",
+	"$":   "USD",
+	"£":   "GBP",
+	"¥":   "JPY",
+	"₹":   "INR",
+	"₽":   "RUB",
+	"CHF": "CHF",
+}
+
+var booleans = map[string]bool{
+	"true":  true,
+	"yes":   true,
+	"on":    true,
+	"1":     true,
+	"false": false,
+	"no":    false,
+	"off":   false,
+	"0":     false,
+}
+
+// checkItemTypes validates the declared types and patterns of all configured items
+func checkItemTypes(job *api.Job) error {
+	for item, cfg := range job.Items {
+		switch cfg.Type {
+		case "", typeString, typeNumber, typeBoolean, typeURL, typeCurrency:
+		default:
+			return fmt.Errorf("item %q: unknown type %q", item, cfg.Type)
+		}
+
+		if cfg.Pattern != "" {
+			if _, err := regexp.Compile(cfg.Pattern); err != nil {
+				return fmt.Errorf("item %q: invalid pattern: %v", item, err)
+			}
+		}
+
+		if _, err := decimalSeparator(cfg.Locale); err != nil {
+			return fmt.Errorf("item %q: %v", item, err)
+		}
+	}
+
+	if job.OnInvalid != "" && job.OnInvalid != onInvalidReject && job.OnInvalid != onInvalidFlag {
+		return fmt.Errorf("unknown on_invalid action %q", job.OnInvalid)
+	}
+
+	return nil
+}
+
+// validateValues checks the normalized results of a run against the declared items and returns all problems found
+func (w *Watcher) validateValues(job *api.Job, values map[string]string) []string {
+	var problems []string
+
+	// items are declared by their type, other item settings may apply to optional items
+	for item, cfg := range job.Items {
+		if _, ok := values[item]; !ok && item != allItems && cfg.Type != "" {
+			problems = append(problems, fmt.Sprintf("item %q is missing", item))
+		}
+	}
+
+	for item, value := range values {
+		if _, ok := collectionOf(job, item); ok {
+			continue
+		}
+
+		value, err := w.normalize(job, item, value)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		cfg := itemConfig(job, item)
+		if _, err := canonicalValue(cfg, value); err != nil {
+			problems = append(problems, fmt.Sprintf("item %q: %v", item, err))
+			continue
+		}
+
+		if cfg.Pattern != "" {
+			if re, err := regexp.Compile(cfg.Pattern); err == nil && !re.MatchString(value) {
+				problems = append(problems, fmt.Sprintf("item %q: value %q does not match %q", item, value, cfg.Pattern))
+			}
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// canonicalValue returns the form of a value its item type is compared in, e.g. "1,000.50" becomes "1000.5"
+func canonicalValue(cfg api.ItemConfig, value string) (string, error) {
+	switch cfg.Type {
+	case typeNumber:
+		f, err := parseNumber(value, cfg.Locale)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case typeCurrency:
+		code, f, err := parseCurrency(value, cfg.Locale)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(code + " " + strconv.FormatFloat(f, 'f', -1, 64)), nil
+	case typeBoolean:
+		b, ok := booleans[strings.ToLower(strings.TrimSpace(value))]
+		if !ok {
+			return "", fmt.Errorf("%q is not a boolean", value)
+		}
+		return strconv.FormatBool(b), nil
+	case typeURL:
+		return canonicalURL(value)
+	}
+
+	return value, nil
+}
+
+// parseCurrency splits a price like "€ 12,99", "12.99 EUR" or "USD -3" into its currency code and amount
+func parseCurrency(value, locale string) (string, float64, error) {
+	s := strings.TrimSpace(value)
+	isAmount := func(r rune) bool {
+		return unicode.IsDigit(r) || r == '.' || r == ',' || r == '-' || r == '+' || r == '\'' || unicode.IsSpace(r)
+	}
+
+	start := strings.IndexFunc(s, isAmount)
+	end := strings.LastIndexFunc(s, isAmount)
+	if start < 0 || !strings.ContainsAny(s, "0123456789") {
+		return "", 0, fmt.Errorf("%q is not a currency amount", value)
+	}
+
+	code := strings.TrimSpace(s[:start] + s[end+1:])
+	if strings.TrimSpace(s[:start]) != "" && strings.TrimSpace(s[end+1:]) != "" {
+		return "", 0, fmt.Errorf("%q is not a currency amount", value)
+	}
+
+	amount, err := parseNumber(s[start:end+1], locale)
+	if err != nil {
+		return "", 0, fmt.Errorf("%q is not a currency amount", value)
+	}
+
+	if c, ok := currencySymbols[code]; ok {
+		code = c
+	}
+
+	return strings.ToUpper(code), amount, nil
+}
+
+// canonicalURL lower cases the scheme and host of a URL and drops default ports
+func canonicalURL(value string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("%q is not an absolute URL", value)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && strings.HasSuffix(u.Host, ":80")) || (u.Scheme == "https" && strings.HasSuffix(u.Host, ":443")) {
+		u.Host = u.Host[:strings.LastIndex(u.Host, ":")]
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	return u.String(), nil
+}
+
+// annotateTypes sets the declared type of the changed items and describes the change of numbers
+func annotateTypes(job *api.Job, diff []api.Diff) []api.Diff {
+	for i, d := range diff {
+		cfg := itemConfig(job, d.Item)
+		diff[i].Type = cfg.Type
+		if diff[i].Type == "" {
+			diff[i].Type = typeString
+		}
+
+		if d.Kind == api.DiffChanged {
+			diff[i].Change = describeChange(cfg, d.OldValue, d.NewValue)
+		}
+	}
+
+	return diff
+}
+
+// describeChange returns the difference of two numbers or amounts of the same currency, like "+2.5 (+10.0%)"
+func describeChange(cfg api.ItemConfig, oldValue, newValue string) string {
+	var oldAmount, newAmount float64
+	var err error
+
+	switch cfg.Type {
+	case typeNumber:
+		if oldAmount, err = parseNumber(oldValue, cfg.Locale); err != nil {
+			return ""
+		}
+		if newAmount, err = parseNumber(newValue, cfg.Locale); err != nil {
+			return ""
+		}
+	case typeCurrency:
+		var oldCode, newCode string
+		if oldCode, oldAmount, err = parseCurrency(oldValue, cfg.Locale); err != nil {
+			return ""
+		}
+		if newCode, newAmount, err = parseCurrency(newValue, cfg.Locale); err != nil || oldCode != newCode {
+			return ""
+		}
+	default:
+		return ""
+	}
+
+	change := fmt.Sprintf("%+g", newAmount-oldAmount)
+	if oldAmount != 0 {
+		change += fmt.Sprintf(" (%+.1f%%)", (newAmount-oldAmount)/math.Abs(oldAmount)*100)
+	}
+
+	return change
+}
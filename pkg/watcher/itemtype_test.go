@@ -0,0 +1,125 @@
+package watcher
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestValidateValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		items  map[string]api.ItemConfig
+		values map[string]string
+		want   []string
+	}{
+		{
+			name:   "valid number",
+			items:  map[string]api.ItemConfig{"price": {Type: typeNumber}},
+			values: map[string]string{"price": "1,000.50"},
+		},
+		{
+			name:   "invalid number",
+			items:  map[string]api.ItemConfig{"price": {Type: typeNumber}},
+			values: map[string]string{"price": "n/a"},
+			want:   []string{`item "price": "n/a" is not a number`},
+		},
+		{
+			name: "number normalized before validation",
+			items: map[string]api.ItemConfig{"price": {
+				Type:      typeNumber,
+				Pattern:   `^\d+(\.\d+)?$`,
+				Normalize: []api.NormalizeStep{{Type: "number", Locale: "de"}},
+			}},
+			values: map[string]string{"price": "1.234,50 €"},
+		},
+		{
+			name:   "pattern mismatch",
+			items:  map[string]api.ItemConfig{"sku": {Pattern: `^[A-Z]{3}-[0-9]+$`}},
+			values: map[string]string{"sku": "abc-1"},
+			want:   []string{`item "sku": value "abc-1" does not match "^[A-Z]{3}-[0-9]+$"`},
+		},
+		{
+			name:   "missing typed item",
+			items:  map[string]api.ItemConfig{"price": {Type: typeNumber}, "title": {Normalize: []api.NormalizeStep{{Type: "trim"}}}},
+			values: map[string]string{},
+			want:   []string{`item "price" is missing`},
+		},
+		{
+			name:   "invalid boolean and url",
+			items:  map[string]api.ItemConfig{"stock": {Type: typeBoolean}, "link": {Type: typeURL}},
+			values: map[string]string{"stock": "maybe", "link": "/relative"},
+			want:   []string{`item "link": "/relative" is not an absolute URL`, `item "stock": "maybe" is not a boolean`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWatcher(t, newMemStorage(), api.Job{Name: "job", Items: tt.items})
+			if got := w.validateValues(&w.config.Jobs[0], tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     api.ItemConfig
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "string", value: " as is ", want: " as is "},
+		{name: "number", cfg: api.ItemConfig{Type: typeNumber}, value: "1,000.50", want: "1000.5"},
+		{name: "number with locale", cfg: api.ItemConfig{Type: typeNumber, Locale: "de"}, value: "1.000", want: "1000"},
+		{name: "invalid number", cfg: api.ItemConfig{Type: typeNumber}, value: "n/a", wantErr: true},
+		{name: "currency symbol", cfg: api.ItemConfig{Type: typeCurrency}, value: "€ 12,99", want: "EUR 12.99"},
+		{name: "currency code", cfg: api.ItemConfig{Type: typeCurrency}, value: "12.99 usd", want: "USD 12.99"},
+		{name: "currency without code", cfg: api.ItemConfig{Type: typeCurrency}, value: "12.99", want: "12.99"},
+		{name: "currency on both sides", cfg: api.ItemConfig{Type: typeCurrency}, value: "€ 12 EUR", wantErr: true},
+		{name: "boolean", cfg: api.ItemConfig{Type: typeBoolean}, value: " Yes ", want: "true"},
+		{name: "invalid boolean", cfg: api.ItemConfig{Type: typeBoolean}, value: "maybe", wantErr: true},
+		{name: "url", cfg: api.ItemConfig{Type: typeURL}, value: "HTTPS://Example.COM:443", want: "https://example.com/"},
+		{name: "relative url", cfg: api.ItemConfig{Type: typeURL}, value: "/relative", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonicalValue(tt.cfg, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      api.ItemConfig
+		oldValue string
+		newValue string
+		want     string
+	}{
+		{name: "number", cfg: api.ItemConfig{Type: typeNumber}, oldValue: "10", newValue: "12.5", want: "+2.5 (+25.0%)"},
+		{name: "from zero", cfg: api.ItemConfig{Type: typeNumber}, oldValue: "0", newValue: "5", want: "+5"},
+		{name: "currency", cfg: api.ItemConfig{Type: typeCurrency}, oldValue: "€ 20", newValue: "€ 15", want: "-5 (-25.0%)"},
+		{name: "different currencies", cfg: api.ItemConfig{Type: typeCurrency}, oldValue: "€ 20", newValue: "$ 20"},
+		{name: "invalid number", cfg: api.ItemConfig{Type: typeNumber}, oldValue: "n/a", newValue: "5"},
+		{name: "string", oldValue: "1", newValue: "2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeChange(tt.cfg, tt.oldValue, tt.newValue); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
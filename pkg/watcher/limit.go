@@ -0,0 +1,148 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+var (
+	queuedExecutions  = expvar.NewInt("queued_executions")
+	runningExecutions = expvar.NewInt("running_executions")
+	droppedExecutions = expvar.NewInt("dropped_executions")
+	queuedByTag       = expvar.NewMap("queued_executions_by_tag")
+)
+
+// errNoSlot is returned for runs dropped because no execution slot became free within max_queue_wait
+var errNoSlot = errors.New("no execution slot free")
+
+// limiter limits the number of concurrent puppet-master executions, globally and per job tag
+type limiter struct {
+	global  chan struct{}
+	tags    map[string]chan struct{}
+	maxWait time.Duration
+}
+
+func newLimiter(cfg *api.ConcurrencyConfig) *limiter {
+	l := &limiter{tags: make(map[string]chan struct{})}
+	if cfg == nil {
+		return l
+	}
+
+	if cfg.MaxExecutions > 0 {
+		l.global = make(chan struct{}, cfg.MaxExecutions)
+	}
+
+	for tag, max := range cfg.TagLimits {
+		if max > 0 {
+			l.tags[tag] = make(chan struct{}, max)
+		}
+	}
+
+	l.maxWait = time.Duration(cfg.MaxQueueWait)
+	return l
+}
+
+// acquire waits for a free execution slot of a job, until max_queue_wait or the context is done.
+// The returned func frees it again. Tag slots are taken before the global one, so waiting for a busy
+// tag doesn't block other jobs.
+func (l *limiter) acquire(ctx context.Context, job *api.Job) (func(), error) {
+	var slots []chan struct{}
+	var tags []string
+	for _, tag := range sortedTags(job.Tags) {
+		if slot, ok := l.tags[tag]; ok {
+			slots = append(slots, slot)
+			tags = append(tags, tag)
+		}
+	}
+	if l.global != nil {
+		slots = append(slots, l.global)
+	}
+
+	var timeout <-chan time.Time
+	if l.maxWait > 0 {
+		timer := time.NewTimer(l.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	queuedExecutions.Add(1)
+	for _, tag := range tags {
+		queuedByTag.Add(tag, 1)
+	}
+	defer func() {
+		queuedExecutions.Add(-1)
+		for _, tag := range tags {
+			queuedByTag.Add(tag, -1)
+		}
+	}()
+
+	release := func(acquired []chan struct{}) {
+		for _, slot := range acquired {
+			<-slot
+		}
+	}
+
+	for i, slot := range slots {
+		select {
+		case slot <- struct{}{}:
+		case <-timeout:
+			release(slots[:i])
+			droppedExecutions.Add(1)
+			return nil, errNoSlot
+		case <-ctx.Done():
+			release(slots[:i])
+			return nil, fmt.Errorf("no execution slot free: %v", ctx.Err())
+		}
+	}
+
+	runningExecutions.Add(1)
+	return func() {
+		runningExecutions.Add(-1)
+		release(slots)
+	}, nil
+}
+
+// sortedTags returns the unique tags in a stable order, so jobs always take slots in the same order
+func sortedTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var sorted []string
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			sorted = append(sorted, tag)
+		}
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// checkConcurrency validates the concurrency settings
+func (w *Watcher) checkConcurrency() error {
+	cfg := w.config.Concurrency
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.MaxExecutions < 0 {
+		return fmt.Errorf("max_executions must not be negative")
+	}
+
+	for tag, max := range cfg.TagLimits {
+		if max < 0 {
+			return fmt.Errorf("limit of tag %q must not be negative", tag)
+		}
+	}
+
+	if cfg.MaxQueueWait < 0 {
+		return fmt.Errorf("max_queue_wait must not be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package watcher
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestLimiterAcquire(t *testing.T) {
+	wait := api.Duration(50 * time.Millisecond)
+
+	tests := []struct {
+		name    string
+		cfg     *api.ConcurrencyConfig
+		held    []api.Job
+		job     api.Job
+		wantErr error
+	}{
+		{
+			name: "no limits",
+			held: []api.Job{{Name: "a"}, {Name: "b"}},
+			job:  api.Job{Name: "c"},
+		},
+		{
+			name: "global slot free",
+			cfg:  &api.ConcurrencyConfig{MaxExecutions: 2, MaxQueueWait: wait},
+			held: []api.Job{{Name: "a"}},
+			job:  api.Job{Name: "b"},
+		},
+		{
+			name:    "global limit reached",
+			cfg:     &api.ConcurrencyConfig{MaxExecutions: 1, MaxQueueWait: wait},
+			held:    []api.Job{{Name: "a"}},
+			job:     api.Job{Name: "b"},
+			wantErr: errNoSlot,
+		},
+		{
+			name:    "tag limit reached",
+			cfg:     &api.ConcurrencyConfig{TagLimits: map[string]int{"shop": 1}, MaxQueueWait: wait},
+			held:    []api.Job{{Name: "a", Tags: []string{"shop"}}},
+			job:     api.Job{Name: "b", Tags: []string{"news", "shop"}},
+			wantErr: errNoSlot,
+		},
+		{
+			name: "untagged job not limited by tag",
+			cfg:  &api.ConcurrencyConfig{TagLimits: map[string]int{"shop": 1}, MaxQueueWait: wait},
+			held: []api.Job{{Name: "a", Tags: []string{"shop"}}},
+			job:  api.Job{Name: "b"},
+		},
+		{
+			name: "duplicate tag takes one slot",
+			cfg:  &api.ConcurrencyConfig{TagLimits: map[string]int{"shop": 1}, MaxQueueWait: wait},
+			job:  api.Job{Name: "a", Tags: []string{"shop", "shop"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLimiter(tt.cfg)
+			for i := range tt.held {
+				release, err := l.acquire(context.Background(), &tt.held[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer release()
+			}
+
+			release, err := l.acquire(context.Background(), &tt.job)
+			if err != tt.wantErr {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				release()
+			}
+		})
+	}
+}
+
+func TestLimiterDropReleasesSlots(t *testing.T) {
+	l := newLimiter(&api.ConcurrencyConfig{
+		MaxExecutions: 1,
+		TagLimits:     map[string]int{"shop": 1},
+		MaxQueueWait:  api.Duration(50 * time.Millisecond),
+	})
+
+	release, err := l.acquire(context.Background(), &api.Job{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the tag slot taken while waiting for the global one is freed again on the drop
+	shop := &api.Job{Name: "b", Tags: []string{"shop"}}
+	if _, err := l.acquire(context.Background(), shop); err != errNoSlot {
+		t.Fatalf("got error %v, want %v", err, errNoSlot)
+	}
+	if len(l.tags["shop"]) != 0 {
+		t.Fatalf("got %d tag slots held after drop, want 0", len(l.tags["shop"]))
+	}
+
+	// a freed slot is taken by a waiting run
+	acquired := make(chan error)
+	go func() {
+		release, err := l.acquire(context.Background(), shop)
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	release()
+	if err := <-acquired; err != nil {
+		t.Errorf("got error %v after slot was freed", err)
+	}
+}
+
+func TestLimiterContextDone(t *testing.T) {
+	l := newLimiter(&api.ConcurrencyConfig{MaxExecutions: 1})
+
+	release, err := l.acquire(context.Background(), &api.Job{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx, &api.Job{Name: "b"}); err == nil || err == errNoSlot {
+		t.Errorf("got error %v, want context error", err)
+	}
+}
+
+func TestFixtureTakesNoSlot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "1.json"), []byte(`{"results": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	job := api.Job{Name: "job", Executor: executorFixture, Fixture: &api.FixtureConfig{Dir: dir}}
+	w := newTestWatcher(t, newMemStorage(), job)
+	w.limiter = newLimiter(&api.ConcurrencyConfig{MaxExecutions: 1, MaxQueueWait: api.Duration(50 * time.Millisecond)})
+
+	release, err := w.limiter.acquire(context.Background(), &api.Job{Name: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	if _, _, err := w.executeJob(context.Background(), &w.config.Jobs[0]); err != nil {
+		t.Errorf("got error %v executing fixture with all slots taken", err)
+	}
+}
@@ -0,0 +1,317 @@
+package watcher
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+const allItems = "*"
+
+// normalizer transforms a value before it is compared
+type normalizer func(value string) string
+
+// decimalSeparators maps locales to the decimal separator used by them
+var decimalSeparators = map[string]rune{
+	"en":    '.',
+	"ja":    '.',
+	"zh":    '.',
+	"de-ch": '.',
+	"de":    ',',
+	"fr":    ',',
+	"es":    ',',
+	"it":    ',',
+	"nl":    ',',
+	"pt":    ',',
+	"pl":    ',',
+	"ru":    ',',
+}
+
+var whitespaceRegExp = regexp.MustCompile(`\s+`)
+
+// newNormalizer creates the normalizer defined by the given step
+func newNormalizer(step api.NormalizeStep) (normalizer, error) {
+	switch step.Type {
+	case "trim":
+		return strings.TrimSpace, nil
+	case "collapse_whitespace":
+		return func(value string) string {
+			return whitespaceRegExp.ReplaceAllString(value, " ")
+		}, nil
+	case "lowercase":
+		return strings.ToLower, nil
+	case "regex_replace":
+		re, err := regexp.Compile(step.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", step.Pattern, err)
+		}
+		return func(value string) string {
+			return re.ReplaceAllString(value, step.Replacement)
+		}, nil
+	case "strip_query_params":
+		for _, p := range step.Params {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("invalid query param pattern %q: %v", p, err)
+			}
+		}
+		return func(value string) string {
+			return stripQueryParams(value, step.Params)
+		}, nil
+	case "number":
+		if _, err := decimalSeparator(step.Locale); err != nil {
+			return nil, err
+		}
+		return func(value string) string {
+			f, err := parseNumber(value, step.Locale)
+			if err != nil {
+				return value
+			}
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown normalization type %q", step.Type)
+}
+
+// itemNormalizers returns the normalization chain of an item. Steps configured for all items run first.
+func itemNormalizers(job *api.Job, item string) ([]normalizer, error) {
+	var steps []api.NormalizeStep
+	steps = append(steps, job.Items[allItems].Normalize...)
+	if item != allItems {
+		steps = append(steps, job.Items[item].Normalize...)
+	}
+
+	normalizers := make([]normalizer, 0, len(steps))
+	for _, step := range steps {
+		n, err := newNormalizer(step)
+		if err != nil {
+			return nil, fmt.Errorf("item %q: %v", item, err)
+		}
+		normalizers = append(normalizers, n)
+	}
+
+	return normalizers, nil
+}
+
+// jobNormalizers are the normalization chains of the configured items of a job, by item name
+type jobNormalizers map[string][]normalizer
+
+// compileNormalizers creates the normalization chains of all configured items of a job. Items without
+// settings of their own use the chain of all items.
+func compileNormalizers(job *api.Job) (jobNormalizers, error) {
+	all, err := itemNormalizers(job, allItems)
+	if err != nil {
+		return nil, err
+	}
+
+	chains := jobNormalizers{allItems: all}
+	for item := range job.Items {
+		if chains[item], err = itemNormalizers(job, item); err != nil {
+			return nil, err
+		}
+	}
+
+	return chains, nil
+}
+
+// checkNormalizers validates the normalization chains of a job and keeps them for its runs
+func (w *Watcher) checkNormalizers(job *api.Job) error {
+	chains, err := compileNormalizers(job)
+	if err != nil {
+		return err
+	}
+
+	w.normalizersMu.Lock()
+	w.normalizers[job.Name] = chains
+	w.normalizersMu.Unlock()
+
+	return nil
+}
+
+// jobNormalizers returns the normalization chains of a job, compiling them on first use
+func (w *Watcher) jobNormalizers(job *api.Job) (jobNormalizers, error) {
+	w.normalizersMu.Lock()
+	chains, ok := w.normalizers[job.Name]
+	w.normalizersMu.Unlock()
+	if ok {
+		return chains, nil
+	}
+
+	if err := w.checkNormalizers(job); err != nil {
+		return nil, err
+	}
+
+	return w.jobNormalizers(job)
+}
+
+// normalize applies the configured normalization chain of an item to the given value
+func (w *Watcher) normalize(job *api.Job, item, value string) (string, error) {
+	chains, err := w.jobNormalizers(job)
+	if err != nil {
+		return "", err
+	}
+
+	normalizers, ok := chains[item]
+	if !ok {
+		normalizers = chains[allItems]
+	}
+
+	for _, n := range normalizers {
+		value = n(value)
+	}
+
+	return value, nil
+}
+
+// normalizeValue returns the form of a value it is compared in: normalized and, for typed items, canonical
+func (w *Watcher) normalizeValue(job *api.Job, item, value string) (string, error) {
+	value, err := w.normalize(job, item, value)
+	if err != nil {
+		return "", err
+	}
+
+	// typed values are compared in their canonical form, invalid ones as they are
+	if canonical, err := canonicalValue(itemConfig(job, item), value); err == nil {
+		value = canonical
+	}
+
+	return value, nil
+}
+
+// stripQueryParams removes the query params matching the given patterns from a URL.
+// All params are removed if no pattern is given. Values not looking like a URL are returned as they are.
+func stripQueryParams(value string, patterns []string) string {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || u.RawQuery == "" {
+		return value
+	}
+
+	if len(patterns) == 0 {
+		u.RawQuery = ""
+		return u.String()
+	}
+
+	query := u.Query()
+	for param := range query {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, param); ok {
+				query.Del(param)
+				break
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// decimalSeparator returns the decimal separator used by the given locale, defaulting to "."
+func decimalSeparator(locale string) (rune, error) {
+	if locale == "" {
+		return '.', nil
+	}
+
+	locale = strings.ToLower(strings.Replace(locale, "_", "-", -1))
+	if sep, ok := decimalSeparators[locale]; ok {
+		return sep, nil
+	}
+
+	if sep, ok := decimalSeparators[strings.SplitN(locale, "-", 2)[0]]; ok {
+		return sep, nil
+	}
+
+	return 0, fmt.Errorf("unknown number locale %q", locale)
+}
+
+// parseNumber parses the number of a formatted value like "1.234,50 €" or "-1,234.5", ignoring the text around
+// it and grouping characters. Without a locale, the decimal separator is guessed by guessDecimalSeparator.
+func parseNumber(value, locale string) (float64, error) {
+	sep, err := decimalSeparator(locale)
+	if err != nil {
+		return 0, err
+	}
+
+	s := strings.TrimSpace(value)
+	start := strings.IndexFunc(s, isDigit)
+	end := strings.LastIndexFunc(s, isDigit)
+	if start < 0 {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+
+	// a leading separator belongs to the number, like in ".5"
+	if start > 0 && (s[start-1] == '.' || s[start-1] == ',') {
+		start--
+	}
+	negative := start > 0 && s[start-1] == '-'
+
+	number := s[start : end+1]
+	if locale == "" {
+		sep = guessDecimalSeparator(number)
+	}
+
+	var b strings.Builder
+	if negative {
+		b.WriteRune('-')
+	}
+	for _, r := range number {
+		switch {
+		case isDigit(r):
+			b.WriteRune(r)
+		case r == sep:
+			b.WriteRune('.')
+		case r == '.' || r == ',' || r == '\'' || unicode.IsSpace(r):
+			// grouping characters
+		default:
+			return 0, fmt.Errorf("%q is not a number", value)
+		}
+	}
+
+	f, err := strconv.ParseFloat(b.String(), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+
+	return f, nil
+}
+
+// guessDecimalSeparator guesses the decimal separator of a number without locale. If both "." and "," are
+// used, the last one is. A single "." always is, so canonical numbers like "1234.567" parse the same again.
+// A single "," is, unless it is followed by exactly three digits like in "1,000".
+func guessDecimalSeparator(number string) rune {
+	i := strings.LastIndexAny(number, ".,")
+	if i < 0 {
+		return '.'
+	}
+
+	last, other := rune(number[i]), ','
+	if last == ',' {
+		other = '.'
+	}
+
+	// both are used, e.g. "1.000,50"
+	if strings.ContainsRune(number[:i], other) {
+		return last
+	}
+
+	// used several times to group digits, e.g. "1.000.000"
+	if strings.Count(number, string(last)) > 1 {
+		return other
+	}
+
+	if last == ',' && len(strings.TrimRightFunc(number[i+1:], unicode.IsSpace)) == 3 {
+		return '.'
+	}
+
+	return last
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
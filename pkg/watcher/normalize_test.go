@@ -0,0 +1,151 @@
+package watcher
+
+import (
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    string
+		all     []api.NormalizeStep
+		steps   []api.NormalizeStep
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "no steps",
+			value: " Value ",
+			want:  " Value ",
+		},
+		{
+			name:  "chain in order",
+			steps: []api.NormalizeStep{{Type: "trim"}, {Type: "collapse_whitespace"}, {Type: "lowercase"}},
+			value: "  Some   Value ",
+			want:  "some value",
+		},
+		{
+			name:  "steps for all items first",
+			all:   []api.NormalizeStep{{Type: "trim"}},
+			steps: []api.NormalizeStep{{Type: "regex_replace", Pattern: `^\d+`, Replacement: "#"}},
+			value: " 12 items",
+			want:  "# items",
+		},
+		{
+			name:  "item without own steps",
+			item:  "other",
+			all:   []api.NormalizeStep{{Type: "trim"}},
+			steps: []api.NormalizeStep{{Type: "lowercase"}},
+			value: " Value ",
+			want:  "Value",
+		},
+		{
+			name:  "strip matching query params",
+			steps: []api.NormalizeStep{{Type: "strip_query_params", Params: []string{"utm_*"}}},
+			value: "https://example.com/p?utm_source=a&id=1",
+			want:  "https://example.com/p?id=1",
+		},
+		{
+			name:  "strip all query params",
+			steps: []api.NormalizeStep{{Type: "strip_query_params"}},
+			value: "https://example.com/p?id=1",
+			want:  "https://example.com/p",
+		},
+		{
+			name:  "strip query params of no URL",
+			steps: []api.NormalizeStep{{Type: "strip_query_params"}},
+			value: "no url",
+			want:  "no url",
+		},
+		{
+			name:  "number",
+			steps: []api.NormalizeStep{{Type: "number", Locale: "de"}},
+			value: "1.234,50 €",
+			want:  "1234.5",
+		},
+		{
+			name:  "invalid number kept",
+			steps: []api.NormalizeStep{{Type: "number"}},
+			value: "n/a",
+			want:  "n/a",
+		},
+		{
+			name:    "unknown type",
+			steps:   []api.NormalizeStep{{Type: "uppercase"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			steps:   []api.NormalizeStep{{Type: "regex_replace", Pattern: "("}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown locale",
+			steps:   []api.NormalizeStep{{Type: "number", Locale: "xx"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWatcher(t, newMemStorage(), api.Job{Name: "job", Items: map[string]api.ItemConfig{
+				allItems: {Normalize: tt.all},
+				"item":   {Normalize: tt.steps},
+			}})
+
+			item := tt.item
+			if item == "" {
+				item = "item"
+			}
+
+			got, err := w.normalize(&w.config.Jobs[0], item, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		value   string
+		locale  string
+		want    float64
+		wantErr bool
+	}{
+		{value: "42", want: 42},
+		{value: "1234.567", want: 1234.567},
+		{value: ".5", want: 0.5},
+		{value: "1,5", want: 1.5},
+		{value: "1,000", want: 1000},
+		{value: "1.000.000", want: 1000000},
+		{value: "1.234,50 €", want: 1234.5},
+		{value: "-1,234.5", want: -1234.5},
+		{value: "Price: $ 12.99 incl. tax", want: 12.99},
+		{value: "1.234", locale: "de", want: 1234},
+		{value: "1,234", locale: "en", want: 1234},
+		{value: "12,5", locale: "de_AT", want: 12.5},
+		{value: "CHF 1'234.50", locale: "de-CH", want: 1234.5},
+		{value: "n/a", wantErr: true},
+		{value: "1a2", wantErr: true},
+		{value: "12", locale: "xx", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value+" "+tt.locale, func(t *testing.T) {
+			got, err := parseNumber(tt.value, tt.locale)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package watcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+// deferredRun holds the results of a run which couldn't be compared while the storage was unavailable
+type deferredRun struct {
+	run     api.Run
+	results map[string]interface{}
+}
+
+// storageUnavailable reports whether the storage is buffering writes during an outage
+func (w *Watcher) storageUnavailable() bool {
+	s, ok := w.storage.(bufferedStorage)
+	return ok && !s.Healthy()
+}
+
+// deferRun keeps the results of a run until the storage is available again, replacing older results of the job.
+// Only the latest results of each job are kept, so deferred runs are bounded by the number of jobs.
+func (w *Watcher) deferRun(job *api.Job, run api.Run, results map[string]interface{}) {
+	w.deferredMu.Lock()
+	defer w.deferredMu.Unlock()
+
+	w.logger.Warnf("Storage unavailable, deferring the comparison of job %q until it is back", job.Name)
+	w.deferred[job.Name] = deferredRun{run: run, results: results}
+}
+
+// deferOnOutage defers a run if it failed reading the storage during an outage, returning err otherwise.
+// It must only be used for failures before notifying, as a deferred run notifies again.
+func (w *Watcher) deferOnOutage(job *api.Job, run api.Run, results map[string]interface{}, err error) error {
+	if !w.storageUnavailable() {
+		return err
+	}
+
+	w.logger.Debugf("Run of job %q failed during storage outage: %v", job.Name, err)
+	w.deferRun(job, run, results)
+	return nil
+}
+
+func (w *Watcher) dropDeferred(jobName string) {
+	w.deferredMu.Lock()
+	defer w.deferredMu.Unlock()
+
+	delete(w.deferred, jobName)
+}
+
+// processDeferred compares the results of all runs deferred during a storage outage
+func (w *Watcher) processDeferred() {
+	w.deferredMu.Lock()
+	deferred := w.deferred
+	w.deferred = make(map[string]deferredRun)
+	w.deferredMu.Unlock()
+
+	for name, d := range deferred {
+		job := w.findJob(name)
+		if job == nil {
+			continue
+		}
+
+		w.logger.Infof("Comparing results of job %q deferred since %s", name, d.run.StartedAt.Format(time.RFC3339))
+
+		ctx, cancel := context.WithTimeout(context.Background(), w.timeout(job))
+		err := withContext(ctx, func() error {
+			return w.process(ctx, job, d.run, d.results)
+		})
+		cancel()
+
+		if err != nil {
+			w.logger.WithField("failure", failureKind(err)).Errorf("failed to compare deferred results of job %q: %v", name, err)
+		}
+	}
+}
+
+// MonitorStorage flushes the writes buffered during storage outages in the given interval until the
+// context is done. Once the storage is available again, deferred runs are compared.
+func (w *Watcher) MonitorStorage(ctx context.Context, interval time.Duration) {
+	s, ok := w.storage.(bufferedStorage)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	available := s.Healthy()
+	for {
+		recovered, err := s.Flush()
+		switch {
+		case err != nil && available:
+			w.logger.Warnf("Storage unavailable, buffering state until it is back: %v", err)
+			available = false
+		case err != nil:
+			w.logger.Debugf("Storage still unavailable: %v", err)
+		case recovered || !available:
+			w.logger.Info("Storage available again, flushed buffered state")
+			available = true
+			w.processDeferred()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
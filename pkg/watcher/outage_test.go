@@ -0,0 +1,64 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+	"github.com/Scalify/website-content-watcher/pkg/storage"
+)
+
+// outageStorage fails reads of one key like a buffered storage during an outage
+type outageStorage struct {
+	*memStorage
+	failKey string
+	healthy bool
+}
+
+func (o *outageStorage) Get(key string) (string, error) {
+	if key == o.failKey {
+		return "", storage.ErrUnavailable
+	}
+
+	return o.memStorage.Get(key)
+}
+
+func (o *outageStorage) Flush() (bool, error) { return false, nil }
+func (o *outageStorage) Healthy() bool        { return o.healthy }
+
+func TestProcessDefersOnOutage(t *testing.T) {
+	job := api.Job{Name: "job", ConfirmRuns: 2}
+	results := map[string]interface{}{"title": "new"}
+
+	tests := []struct {
+		name         string
+		failKey      string
+		healthy      bool
+		wantErr      bool
+		wantDeferred bool
+	}{
+		{name: "values unavailable", failKey: "job", wantDeferred: true},
+		{name: "pending unavailable", failKey: "job:pending", wantDeferred: true},
+		{name: "read failing while healthy", failKey: "job:pending", healthy: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &outageStorage{memStorage: newMemStorage(), healthy: true}
+			w := newTestWatcher(t, store, job)
+			if err := w.setValues(job.Name, map[string]string{"title": "old"}); err != nil {
+				t.Fatal(err)
+			}
+			store.failKey, store.healthy = tt.failKey, tt.healthy
+
+			err := w.process(context.Background(), &w.config.Jobs[0], api.Run{}, results)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+
+			if _, ok := w.deferred[job.Name]; ok != tt.wantDeferred {
+				t.Errorf("got deferred %v, want %v", ok, tt.wantDeferred)
+			}
+		})
+	}
+}
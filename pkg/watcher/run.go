@@ -0,0 +1,174 @@
+package watcher
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+const defaultTimeout = 10 * time.Minute
+
+var failedRuns = expvar.NewMap("failed_runs")
+
+// runError is an error of a run with the kind of its failure. Repeated failures were reported before.
+type runError struct {
+	kind     api.FailureKind
+	repeated bool
+	err      error
+}
+
+func (e *runError) Error() string {
+	return e.err.Error()
+}
+
+// failureKind returns the kind of a run error, defaulting to api.FailureError
+func failureKind(err error) api.FailureKind {
+	if e, ok := err.(*runError); ok {
+		return e.kind
+	}
+
+	return api.FailureError
+}
+
+// repeatedFailure reports whether a run failed the same way before and was reported already
+func repeatedFailure(err error) bool {
+	e, ok := err.(*runError)
+	return ok && e.repeated
+}
+
+// run executes a job within its timeout and reports failures. Runs waiting too long for an
+// execution slot are dropped without an error.
+func (w *Watcher) run(job *api.Job) error {
+	timeout := w.timeout(job)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := withContext(ctx, func() error {
+		return w.do(ctx, job)
+	})
+	if err == errNoSlot {
+		w.logger.Warnf("Dropping run of job %q: %v within %s", job.Name, err, w.limiter.maxWait)
+		return nil
+	}
+	if err == nil {
+		return nil
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		err = &runError{kind: api.FailureTimeout, err: fmt.Errorf("run of job %q timed out after %s", job.Name, timeout)}
+	}
+
+	kind := failureKind(err)
+	failedRuns.Add(string(kind), 1)
+
+	if notifiesFailure(job, err) {
+		run := api.Run{StartedAt: start, Duration: time.Since(start)}
+		if notifyErr := w.notifyFailure(job, kind, err, run); notifyErr != nil {
+			w.logger.Errorf("failed to notify about failure of job %q: %v", job.Name, notifyErr)
+		}
+	}
+
+	if _, ok := err.(*runError); !ok {
+		err = &runError{kind: kind, err: err}
+	}
+
+	return err
+}
+
+// withContext runs fn, returning early when the context is done. As the puppet-master and storage
+// clients can't be canceled, fn keeps running in the background and has to check the context itself.
+func withContext(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ExecutionTimeout returns the longest timeout of all jobs of a config, which bounds the requests to puppet-master
+func ExecutionTimeout(config *api.Config) time.Duration {
+	w := &Watcher{config: config}
+	max := w.timeout(&api.Job{})
+	for i := range w.config.Jobs {
+		if t := w.timeout(&w.config.Jobs[i]); t > max {
+			max = t
+		}
+	}
+
+	return max
+}
+
+// timeout returns the timeout of a job's runs
+func (w *Watcher) timeout(job *api.Job) time.Duration {
+	if job.Timeout > 0 {
+		return time.Duration(job.Timeout)
+	}
+
+	if w.config.Timeout > 0 {
+		return time.Duration(w.config.Timeout)
+	}
+
+	return defaultTimeout
+}
+
+// notifiesFailure reports whether a failed run is notified. Suspected results are always notified, as their
+// values are kept silently otherwise. Repeated failures are only notified once.
+func notifiesFailure(job *api.Job, err error) bool {
+	if repeatedFailure(err) {
+		return false
+	}
+
+	return job.NotifyOnFailure || failureKind(err) == api.FailureSuspect
+}
+
+// notifyFailure reports a failed run to all notify entries of the job supporting it, ignoring digests.
+// Entries in a quiet window are skipped.
+func (w *Watcher) notifyFailure(job *api.Job, kind api.FailureKind, err error, run api.Run) error {
+	for _, notify := range job.Notify {
+		if notify.Digest {
+			continue
+		}
+
+		window, _, windowErr := w.activeQuietWindow(job, notify, time.Now())
+		if windowErr != nil {
+			return fmt.Errorf("invalid quiet window for %q: %v", notify.Type, windowErr)
+		}
+		if window != nil {
+			w.logger.Infof("Dropping failure notification by %q of job %q during quiet window", notify.Type, job.Name)
+			continue
+		}
+
+		not, getErr := w.getNotifier(notify.Type)
+		if getErr != nil {
+			return getErr
+		}
+
+		failureNot, ok := not.(failureNotifier)
+		if !ok {
+			continue
+		}
+
+		if notifyErr := failureNot.NotifyFailure(api.Failure{
+			Job:     job,
+			Target:  notify.Value,
+			Options: notify.Options,
+			Kind:    kind,
+			Error:   err.Error(),
+			Run:     run,
+		}); notifyErr != nil {
+			return fmt.Errorf("failed to notify by %q: %v", notify.Type, notifyErr)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+	"github.com/robfig/cron"
+)
+
+// JobSchedule describes when a job runs next
+type JobSchedule struct {
+	Name     string
+	Schedule string
+	Location *time.Location
+	Next     time.Time
+}
+
+// locationSchedule evaluates a schedule on the wall clock of a location. Times skipped by a
+// daylight saving change run right after it, times repeated by one run only once, on their first occurrence.
+type locationSchedule struct {
+	schedule cron.Schedule
+	location *time.Location
+}
+
+// Next returns the next activation time after the given one
+func (s locationSchedule) Next(t time.Time) time.Time {
+	// intervals like @every are independent of the wall clock
+	if _, ok := s.schedule.(cron.ConstantDelaySchedule); ok {
+		return s.schedule.Next(t)
+	}
+
+	wall := wallClock(t.In(s.location))
+	for {
+		wall = s.schedule.Next(wall)
+		if wall.IsZero() {
+			return wall
+		}
+
+		next := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, s.location)
+		if first := firstOccurrence(next); first.After(t) {
+			return first
+		}
+		if next.After(t) {
+			return next
+		}
+	}
+}
+
+// spreadSchedule runs at a fixed interval, offset from the full interval by a fixed amount
+type spreadSchedule struct {
+	interval time.Duration
+	offset   time.Duration
+}
+
+// Next returns the next activation time after the given one
+func (s spreadSchedule) Next(t time.Time) time.Time {
+	next := t.Add(-s.offset).Truncate(s.interval).Add(s.offset)
+	if !next.After(t) {
+		next = next.Add(s.interval)
+	}
+
+	return next
+}
+
+// spreadOffset derives a stable offset within the interval from a job name
+func spreadOffset(name string, interval time.Duration) time.Duration {
+	sum := sha256.Sum256([]byte(name))
+
+	return time.Duration(binary.BigEndian.Uint64(sum[:8]) % uint64(interval)).Truncate(time.Second)
+}
+
+// firstOccurrence returns the earlier time of a wall clock time repeated by a daylight saving change, or t itself
+func firstOccurrence(t time.Time) time.Time {
+	_, offset := t.Zone()
+	_, before := t.Add(-24 * time.Hour).Zone()
+
+	if shift := time.Duration(before-offset) * time.Second; shift > 0 {
+		if earlier := t.Add(-shift); wallClock(earlier).Equal(wallClock(t)) {
+			return earlier
+		}
+	}
+
+	return t
+}
+
+// wallClock returns the wall clock time of t as UTC, which has no daylight saving changes
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// location returns the location of a time zone name, defaulting to the global time zone
+func (w *Watcher) location(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		timezone = w.config.Timezone
+	}
+
+	if timezone == "" {
+		return time.Local, nil
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %v", timezone, err)
+	}
+
+	return location, nil
+}
+
+// jobLocation returns the time zone of a job
+func (w *Watcher) jobLocation(job *api.Job) (*time.Location, error) {
+	return w.location(job.Timezone)
+}
+
+// parseSchedule parses a cron spec evaluated in the given time zone
+func (w *Watcher) parseSchedule(spec, timezone string) (cron.Schedule, error) {
+	location, err := w.location(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return locationSchedule{schedule: schedule, location: location}, nil
+}
+
+// jobSchedule returns the schedule of a job, spread over its interval if configured
+func (w *Watcher) jobSchedule(job *api.Job) (cron.Schedule, error) {
+	schedule, err := w.parseSchedule(job.Schedule, job.Timezone)
+	if err != nil || !job.Spread {
+		return schedule, err
+	}
+
+	every, ok := schedule.(locationSchedule).schedule.(cron.ConstantDelaySchedule)
+	if !ok {
+		return nil, fmt.Errorf("spread requires an @every schedule, got %q", job.Schedule)
+	}
+
+	return spreadSchedule{interval: every.Delay, offset: spreadOffset(job.Name, every.Delay)}, nil
+}
+
+// jitter returns a random delay for the next run of a job, up to its configured jitter
+func (w *Watcher) jitter(job *api.Job) time.Duration {
+	max := time.Duration(w.config.Jitter)
+	if job.Jitter != nil {
+		max = time.Duration(*job.Jitter)
+	}
+
+	if max <= 0 {
+		return 0
+	}
+
+	w.randMu.Lock()
+	defer w.randMu.Unlock()
+
+	return time.Duration(w.rand.Int63n(int64(max)))
+}
+
+// checkJitter validates the jitter of a job
+func (w *Watcher) checkJitter(job *api.Job) error {
+	if w.config.Jitter < 0 || (job.Jitter != nil && *job.Jitter < 0) {
+		return fmt.Errorf("jitter must not be negative")
+	}
+
+	return nil
+}
+
+// Schedules returns the next runs of all jobs after the given time
+func (w *Watcher) Schedules(now time.Time) ([]JobSchedule, error) {
+	var schedules []JobSchedule
+	for i := range w.config.Jobs {
+		job := &w.config.Jobs[i]
+
+		schedule, err := w.jobSchedule(job)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule of job %q: %v", job.Name, err)
+		}
+
+		location, err := w.jobLocation(job)
+		if err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, JobSchedule{
+			Name:     job.Name,
+			Schedule: job.Schedule,
+			Location: location,
+			Next:     schedule.Next(now).In(location),
+		})
+	}
+
+	return schedules, nil
+}
@@ -0,0 +1,129 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestJitter(t *testing.T) {
+	global := api.Duration(time.Minute)
+	disabled := api.Duration(0)
+	own := api.Duration(time.Second)
+
+	tests := []struct {
+		name   string
+		jitter *api.Duration
+		max    time.Duration
+	}{
+		{name: "global jitter", max: time.Minute},
+		{name: "job jitter", jitter: &own, max: time.Second},
+		{name: "disabled jitter", jitter: &disabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := api.Job{Name: "job", Jitter: tt.jitter}
+			w := newTestWatcher(t, newMemStorage(), job)
+			w.config.Jitter = global
+
+			for i := 0; i < 100; i++ {
+				if delay := w.jitter(&job); delay < 0 || (delay >= tt.max && tt.max > 0) || (tt.max == 0 && delay != 0) {
+					t.Fatalf("got delay %s, want less than %s", delay, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestCronFuncSkipsJitterOnStop(t *testing.T) {
+	jitter := api.Duration(time.Hour)
+	job := api.Job{Name: "job", Jitter: &jitter}
+	w := newTestWatcher(t, newMemStorage(), job)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.cronFunc(ctx, &w.config.Jobs[0])()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("delayed run was not skipped after stopping")
+	}
+}
+
+func TestLocationScheduleDST(t *testing.T) {
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	// Europe/Berlin skips 02:00-03:00 on March 29 and repeats it on October 25 2026
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			name: "daily time skipped",
+			spec: "0 30 2 * * *",
+			from: utc(time.March, 28, 11, 0),
+			want: []time.Time{utc(time.March, 29, 1, 30), utc(time.March, 30, 0, 30)},
+		},
+		{
+			name: "daily time repeated",
+			spec: "0 30 2 * * *",
+			from: utc(time.October, 24, 22, 0),
+			want: []time.Time{utc(time.October, 25, 0, 30), utc(time.October, 26, 1, 30)},
+		},
+		{
+			name: "daily time repeated, started in between",
+			spec: "0 30 2 * * *",
+			from: utc(time.October, 25, 1, 15),
+			want: []time.Time{utc(time.October, 25, 1, 30), utc(time.October, 26, 1, 30)},
+		},
+		{
+			name: "hourly over skipped hour",
+			spec: "0 0 * * * *",
+			from: utc(time.March, 29, 0, 30),
+			want: []time.Time{utc(time.March, 29, 1, 0), utc(time.March, 29, 2, 0)},
+		},
+		{
+			name: "hourly over repeated hour",
+			spec: "0 0 * * * *",
+			from: utc(time.October, 24, 23, 30),
+			want: []time.Time{utc(time.October, 25, 0, 0), utc(time.October, 25, 2, 0), utc(time.October, 25, 3, 0)},
+		},
+		{
+			name: "interval",
+			spec: "@every 1h",
+			from: utc(time.October, 25, 0, 30),
+			want: []time.Time{utc(time.October, 25, 1, 30), utc(time.October, 25, 2, 30)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWatcher(t, newMemStorage())
+			schedule, err := w.parseSchedule(tt.spec, "Europe/Berlin")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next := tt.from
+			for _, want := range tt.want {
+				next = schedule.Next(next)
+				if !next.Equal(want) {
+					t.Fatalf("got %s, want %s", next.UTC(), want)
+				}
+			}
+		})
+	}
+}
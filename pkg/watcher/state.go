@@ -0,0 +1,181 @@
+package watcher
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Scalify/website-content-watcher/pkg/storage"
+)
+
+// JobState maps job names to their stored values, as exported and imported by the state commands
+type JobState map[string]map[string]string
+
+// checkStateJob ensures the state of a job is only accessed if it is configured
+func (w *Watcher) checkStateJob(jobName string) error {
+	if w.findJob(jobName) == nil {
+		return fmt.Errorf("job %q is not configured", jobName)
+	}
+
+	return nil
+}
+
+// State returns the stored values of a job
+func (w *Watcher) State(jobName string) (map[string]string, error) {
+	if err := w.checkStateJob(jobName); err != nil {
+		return nil, err
+	}
+
+	values, err := w.getValues(jobName)
+	if err != nil && err != storage.ErrNotFound {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// ResetState deletes the stored values of the given items of a job, or of all its items if none are given.
+// Pending changes of the items are dropped as well, so the next run starts from scratch. Resetting all
+// items also deletes the history, restarts the sequence of recorded responses of the fixture executor and
+// forgets suspected results.
+func (w *Watcher) ResetState(jobName string, items []string) error {
+	if err := w.checkStateJob(jobName); err != nil {
+		return err
+	}
+
+	if len(items) == 0 {
+		if err := w.storage.Del(w.cleanJobName(jobName)); err != nil {
+			return fmt.Errorf("failed to delete values: %v", err)
+		}
+
+		if err := w.storage.Del(w.fixtureKey(jobName)); err != nil {
+			return fmt.Errorf("failed to delete fixture position: %v", err)
+		}
+
+		if err := w.storage.Del(w.suspectKey(jobName)); err != nil {
+			return fmt.Errorf("failed to delete suspected results: %v", err)
+		}
+
+		if err := w.storage.Del(w.hashesKey(jobName)); err != nil {
+			return fmt.Errorf("failed to delete hashes: %v", err)
+		}
+
+		historyKeys, err := w.storage.Keys(w.historyKey(jobName, "*"))
+		if err != nil {
+			return fmt.Errorf("failed to list history: %v", err)
+		}
+		for _, key := range historyKeys {
+			if err := w.storage.Del(key); err != nil {
+				return fmt.Errorf("failed to delete history: %v", err)
+			}
+		}
+
+		return w.setPending(jobName, nil)
+	}
+
+	values, err := w.getValues(jobName)
+	if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+
+	pending, err := w.getPending(jobName)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if _, ok := values[item]; !ok {
+			return fmt.Errorf("item %q of job %q has no stored value", item, jobName)
+		}
+		delete(values, item)
+		delete(pending, item)
+	}
+
+	if err := w.setPending(jobName, pending); err != nil {
+		return err
+	}
+
+	return w.setValues(jobName, values)
+}
+
+// SetState stores the value of an item of a job, e.g. to accept a change without waiting for the next run
+func (w *Watcher) SetState(jobName, item, value string) error {
+	if err := w.checkStateJob(jobName); err != nil {
+		return err
+	}
+
+	values, err := w.getValues(jobName)
+	if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+
+	pending, err := w.getPending(jobName)
+	if err != nil {
+		return err
+	}
+
+	values[item] = value
+	delete(pending, item)
+
+	if err := w.setPending(jobName, pending); err != nil {
+		return err
+	}
+
+	return w.setValues(jobName, values)
+}
+
+// ExportState returns the stored values of the given jobs, or of all configured jobs if none are given.
+// Jobs without stored values are left out.
+func (w *Watcher) ExportState(jobNames []string) (JobState, error) {
+	if len(jobNames) == 0 {
+		for _, job := range w.config.Jobs {
+			jobNames = append(jobNames, job.Name)
+		}
+	}
+
+	state := make(JobState)
+	for _, name := range jobNames {
+		values, err := w.State(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(values) > 0 {
+			state[name] = values
+		}
+	}
+
+	return state, nil
+}
+
+// ImportState replaces the stored values of all jobs in the state. All jobs have to be configured.
+// Pending changes, hashes and suspected results belonging to the replaced values are dropped.
+func (w *Watcher) ImportState(state JobState) ([]string, error) {
+	var names []string
+	for name := range state {
+		if err := w.checkStateJob(name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := w.setPending(name, nil); err != nil {
+			return nil, err
+		}
+
+		if err := w.storage.Del(w.hashesKey(name)); err != nil {
+			return nil, fmt.Errorf("failed to delete hashes of job %q: %v", name, err)
+		}
+
+		if err := w.storage.Del(w.suspectKey(name)); err != nil {
+			return nil, fmt.Errorf("failed to delete suspected results of job %q: %v", name, err)
+		}
+
+		if err := w.setValues(name, state[name]); err != nil {
+			return nil, fmt.Errorf("failed to import job %q: %v", name, err)
+		}
+	}
+
+	return names, nil
+}
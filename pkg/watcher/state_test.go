@@ -0,0 +1,181 @@
+package watcher
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+// newStateWatcher returns a watcher of the jobs "job" and "other" with the full state of "job" stored
+func newStateWatcher(t *testing.T) (*Watcher, *memStorage) {
+	t.Helper()
+
+	store := newMemStorage()
+	w := newTestWatcher(t, store, api.Job{Name: "job"}, api.Job{Name: "other"})
+
+	if err := w.setValues("job", map[string]string{"price": "1", "title": "Shop"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.setValues("other", map[string]string{"title": "Other"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.setPending("job", map[string]pendingValue{"price": {Value: "2", Runs: 1}, "title": {Value: "Store", Runs: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"job:history:price", "job:hashes", "job:suspect", "job:fixture"} {
+		store.values[key] = "{}"
+	}
+
+	return w, store
+}
+
+func TestResetState(t *testing.T) {
+	tests := []struct {
+		name        string
+		job         string
+		items       []string
+		wantErr     bool
+		wantValues  map[string]string
+		wantPending []string
+		wantKeys    []string
+	}{
+		{
+			name:        "item",
+			job:         "job",
+			items:       []string{"price"},
+			wantValues:  map[string]string{"title": "Shop"},
+			wantPending: []string{"title"},
+			wantKeys:    []string{jobRegistryKey, "job", "job:fixture", "job:hashes", "job:history:price", "job:pending", "job:suspect", "other"},
+		},
+		{
+			name:     "item without value",
+			job:      "job",
+			items:    []string{"stock"},
+			wantErr:  true,
+			wantKeys: []string{jobRegistryKey, "job", "job:fixture", "job:hashes", "job:history:price", "job:pending", "job:suspect", "other"},
+		},
+		{
+			name:     "whole job",
+			job:      "job",
+			wantKeys: []string{jobRegistryKey, "other"},
+		},
+		{
+			name:     "job not configured",
+			job:      "removed",
+			wantErr:  true,
+			wantKeys: []string{jobRegistryKey, "job", "job:fixture", "job:hashes", "job:history:price", "job:pending", "job:suspect", "other"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, store := newStateWatcher(t)
+
+			err := w.ResetState(tt.job, tt.items)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+
+			if keys := store.keys(); !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("got keys %v, want %v", keys, tt.wantKeys)
+			}
+			if err != nil {
+				return
+			}
+
+			values, err := w.State(tt.job)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(values) > 0 || len(tt.wantValues) > 0 {
+				if !reflect.DeepEqual(values, tt.wantValues) {
+					t.Errorf("got values %v, want %v", values, tt.wantValues)
+				}
+			}
+
+			pending, err := w.getPending(tt.job)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var items []string
+			for item := range pending {
+				items = append(items, item)
+			}
+			if !reflect.DeepEqual(items, tt.wantPending) {
+				t.Errorf("got pending items %v, want %v", items, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestSetState(t *testing.T) {
+	w, _ := newStateWatcher(t)
+
+	if err := w.SetState("job", "price", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetState("removed", "price", "2"); err == nil {
+		t.Error("got no error setting the state of a job not configured")
+	}
+
+	values, err := w.State("job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"price": "2", "title": "Shop"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("got values %v, want %v", values, want)
+	}
+
+	pending, err := w.getPending("job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pending["price"]; ok || len(pending) != 1 {
+		t.Errorf("got pending values %v, want only title", pending)
+	}
+}
+
+func TestExportImportState(t *testing.T) {
+	source, _ := newStateWatcher(t)
+
+	state, err := source.ExportState(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := JobState{"job": {"price": "1", "title": "Shop"}, "other": {"title": "Other"}}
+	if !reflect.DeepEqual(state, want) {
+		t.Fatalf("got exported state %v, want %v", state, want)
+	}
+
+	// the target holds stale state of other values
+	target, store := newStateWatcher(t)
+	if err := target.setValues("job", map[string]string{"price": "5"}); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := target.ImportState(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"job", "other"}) {
+		t.Errorf("got imported jobs %v", names)
+	}
+
+	imported, err := target.ExportState(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(imported, state) {
+		t.Errorf("got state %v after import, want %v", imported, state)
+	}
+
+	wantKeys := []string{jobRegistryKey, "job", "job:fixture", "job:history:price", "other"}
+	if keys := store.keys(); !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("got keys %v after import, want %v", keys, wantKeys)
+	}
+
+	if _, err := target.ImportState(JobState{"removed": {"title": "Gone"}}); err == nil {
+		t.Error("got no error importing a job not configured")
+	}
+}
@@ -6,9 +6,14 @@ import (
 	"log"
 	"regexp"
 
+	"github.com/Scalify/website-content-watcher/pkg/api"
 	"github.com/Scalify/website-content-watcher/pkg/storage"
 )
 
+// globalKeyPrefix starts all keys not belonging to a single job. Job keys are alphanumeric, so neither
+// a job key nor the keys of a job matched by "<job>:*" can ever start with it.
+const globalKeyPrefix = "_"
+
 var (
 	cleanRegExp *regexp.Regexp
 )
@@ -21,33 +26,121 @@ func init() {
 	}
 }
 
+// getValues returns the stored values of a job. If none were stored yet, it returns no values and storage.ErrNotFound.
 func (w *Watcher) getValues(jobName string) (map[string]string, error) {
-	jobName = w.cleanJobName(jobName)
-	valuesStr, err := w.storage.Get(jobName)
-	if err != nil && err != storage.ErrNotFound {
-		return nil, err
-	}
-
 	values := make(map[string]string)
-	if valuesStr == "" {
-		return values, nil
+	str, err := w.storage.Get(w.cleanJobName(jobName))
+	if err == storage.ErrNotFound {
+		return values, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load values: %v", err)
 	}
 
-	if err := json.Unmarshal([]byte(valuesStr), &values); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal values: %v", err)
+	if err := json.Unmarshal([]byte(str), &values); err != nil {
+		return nil, fmt.Errorf("failed to decode values: %v", err)
 	}
 
 	return values, nil
 }
 
 func (w *Watcher) setValues(jobName string, values map[string]string) error {
-	jobName = w.cleanJobName(jobName)
-	valueBytes, err := json.Marshal(values)
+	if err := w.setJSON(w.cleanJobName(jobName), values); err != nil {
+		return fmt.Errorf("failed to store values: %v", err)
+	}
+
+	return w.registerJob(jobName)
+}
+
+func (w *Watcher) getPending(jobName string) (map[string]pendingValue, error) {
+	pending := make(map[string]pendingValue)
+	if err := w.getJSON(w.pendingKey(jobName), &pending); err != nil {
+		return nil, fmt.Errorf("failed to load pending values: %v", err)
+	}
+
+	return pending, nil
+}
+
+func (w *Watcher) setPending(jobName string, pending map[string]pendingValue) error {
+	if len(pending) == 0 {
+		return w.storage.Del(w.pendingKey(jobName))
+	}
+
+	if err := w.setJSON(w.pendingKey(jobName), pending); err != nil {
+		return fmt.Errorf("failed to store pending values: %v", err)
+	}
+
+	return nil
+}
+
+// getHashes returns the hashes of the stored values of a job compared by hash, by value
+func (w *Watcher) getHashes(job *api.Job) (map[string]string, error) {
+	hashes := make(map[string]string)
+	if !comparesByHash(job) {
+		return hashes, nil
+	}
+
+	if err := w.getJSON(w.hashesKey(job.Name), &hashes); err != nil {
+		return nil, fmt.Errorf("failed to load hashes: %v", err)
+	}
+
+	return hashes, nil
+}
+
+// setHashes stores the hashes of the stored and pending values of a job
+func (w *Watcher) setHashes(jobName string, hashes, values map[string]string, pending map[string]pendingValue) error {
+	stored := make(map[string]string)
+	for _, value := range values {
+		if hash, ok := hashes[value]; ok {
+			stored[value] = hash
+		}
+	}
+	for _, p := range pending {
+		if hash, ok := hashes[p.Value]; ok {
+			stored[p.Value] = hash
+		}
+	}
+
+	if len(stored) == 0 {
+		return w.storage.Del(w.hashesKey(jobName))
+	}
+
+	if err := w.setJSON(w.hashesKey(jobName), stored); err != nil {
+		return fmt.Errorf("failed to store hashes: %v", err)
+	}
+
+	return nil
+}
+
+// getJSON decodes the value of a key into target. Missing keys leave target untouched.
+func (w *Watcher) getJSON(key string, target interface{}) error {
+	str, err := w.storage.Get(key)
+	if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+
+	if str == "" {
+		return nil
+	}
+
+	return json.Unmarshal([]byte(str), target)
+}
+
+func (w *Watcher) setJSON(key string, value interface{}) error {
+	b, err := json.Marshal(value)
 	if err != nil {
-		return fmt.Errorf("failed to marshall values: %v", err)
+		return err
 	}
 
-	return w.storage.Set(jobName, string(valueBytes))
+	return w.storage.Set(key, string(b))
+}
+
+func (w *Watcher) pendingKey(jobName string) string {
+	return w.cleanJobName(jobName) + ":pending"
+}
+
+func (w *Watcher) hashesKey(jobName string) string {
+	return w.cleanJobName(jobName) + ":hashes"
 }
 
 func (w *Watcher) cleanJobName(jobName string) string {
@@ -0,0 +1,67 @@
+package watcher
+
+import (
+	"fmt"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+// templates returns the template files of a notifier for a job. Job templates override
+// the global ones, paths are resolved relative to the config file.
+func (w *Watcher) templates(job *api.Job, notifierKey string) api.Templates {
+	templates := w.globalTemplates(notifierKey)
+	override := job.Templates[notifierKey]
+
+	if override.Subject != "" {
+		templates.Subject = w.resolvePath(override.Subject)
+	}
+	if override.Body != "" {
+		templates.Body = w.resolvePath(override.Body)
+	}
+	if override.Text != "" {
+		templates.Text = w.resolvePath(override.Text)
+	}
+
+	return templates
+}
+
+// globalTemplates returns the template files of a notifier, resolved relative to the config file
+func (w *Watcher) globalTemplates(notifierKey string) api.Templates {
+	templates := w.config.Templates[notifierKey]
+
+	for _, file := range []*string{
+		&templates.Subject,
+		&templates.Body,
+		&templates.Text,
+		&templates.DigestSubject,
+		&templates.DigestBody,
+		&templates.DigestText,
+	} {
+		if *file != "" {
+			*file = w.resolvePath(*file)
+		}
+	}
+
+	return templates
+}
+
+// checkTemplates validates the templates of all notifiers of a job
+func (w *Watcher) checkTemplates(job *api.Job) error {
+	for _, notify := range job.Notify {
+		not, err := w.getNotifier(notify.Type)
+		if err != nil {
+			return err
+		}
+
+		checker, ok := not.(templateChecker)
+		if !ok {
+			continue
+		}
+
+		if err := checker.CheckTemplates(job, w.templates(job, notify.Type)); err != nil {
+			return fmt.Errorf("notifier %q: %v", notify.Type, err)
+		}
+	}
+
+	return nil
+}
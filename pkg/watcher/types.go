@@ -9,6 +9,16 @@ type storageClient interface {
 	Get(key string) (string, error)
 	Set(key, value string) error
 	Del(key string) error
+	AddMember(key, member string, score float64) error
+	Members(key string) (map[string]float64, error)
+	RemoveMember(key, member string) error
+	Keys(pattern string) ([]string, error)
+}
+
+// bufferedStorage is implemented by storages buffering writes while their backend is unavailable
+type bufferedStorage interface {
+	Flush() (bool, error)
+	Healthy() bool
 }
 
 type puppetMasterClient interface {
@@ -20,5 +30,30 @@ type puppetMasterClient interface {
 
 type notifier interface {
 	Key() string
-	Notify(jobName, target string, diff []api.Diff, newValues map[string]string) error
+	Notify(notification api.Notification) error
+}
+
+// batchNotifier is implemented by notifiers handling all notifications of a run at once
+type batchNotifier interface {
+	NotifyAll(notifications []api.Notification) error
+}
+
+// failureNotifier is implemented by notifiers able to report failed runs
+type failureNotifier interface {
+	NotifyFailure(failure api.Failure) error
+}
+
+// digestNotifier is implemented by notifiers able to send aggregated digests
+type digestNotifier interface {
+	NotifyDigest(digest api.Digest) error
+}
+
+// templateChecker is implemented by notifiers rendering templates, to validate them on startup
+type templateChecker interface {
+	CheckTemplates(job *api.Job, templates api.Templates) error
+}
+
+// entryChecker is implemented by notifiers validating their notify entries on startup
+type entryChecker interface {
+	CheckEntry(entry api.NotifyEntry) error
 }
@@ -1,10 +1,15 @@
 package watcher
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Scalify/website-content-watcher/pkg/api"
+	"github.com/Scalify/website-content-watcher/pkg/storage"
 	"github.com/Sirupsen/logrus"
 	"github.com/robfig/cron"
 )
@@ -17,36 +22,118 @@ type Watcher struct {
 	notifiers  map[string]notifier
 	configFile string
 	config     *api.Config
+	queueMu    sync.Mutex
+	limiter    *limiter
+	deferredMu sync.Mutex
+	deferred   map[string]deferredRun
+	randMu     sync.Mutex
+	rand       *rand.Rand
+
+	normalizersMu sync.Mutex
+	normalizers   map[string]jobNormalizers
 }
 
 // New returns a new watcher instance
 func New(logger *logrus.Entry, storage storageClient, puppet puppetMasterClient, configFile string, config *api.Config) *Watcher {
 	return &Watcher{
-		logger:     logger,
-		storage:    storage,
-		puppet:     puppet,
-		notifiers:  make(map[string]notifier),
-		configFile: configFile,
-		config:     config,
+		logger:      logger,
+		storage:     storage,
+		puppet:      puppet,
+		notifiers:   make(map[string]notifier),
+		configFile:  configFile,
+		config:      config,
+		limiter:     newLimiter(config.Concurrency),
+		deferred:    make(map[string]deferredRun),
+		normalizers: make(map[string]jobNormalizers),
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
 // CheckConfig checks the config for common and/or known mistakes
 func (w *Watcher) CheckConfig() error {
+	if w.config.Digest != nil && w.config.Digest.Schedule != "" {
+		if _, err := w.parseSchedule(w.config.Digest.Schedule, ""); err != nil {
+			return fmt.Errorf("error parsing cron schedule string for digest: %v", err)
+		}
+	}
+
+	if err := w.checkConcurrency(); err != nil {
+		return fmt.Errorf("invalid concurrency settings: %v", err)
+	}
+
 	for _, job := range w.config.Jobs {
 		for _, not := range job.Notify {
-			if _, err := w.getNotifier(not.Type); err != nil {
+			n, err := w.getNotifier(not.Type)
+			if err != nil {
 				return err
 			}
+
+			if checker, ok := n.(entryChecker); ok {
+				if err := checker.CheckEntry(not); err != nil {
+					return fmt.Errorf("invalid notify entry of job %q: %v", job.Name, err)
+				}
+			}
 		}
 
-		if _, err := cron.Parse(job.Schedule); err != nil {
+		if _, err := w.jobSchedule(&job); err != nil {
 			return fmt.Errorf("error parsing cron schedule string for job: %v", err)
 		}
 
+		if err := checkInitialRun(&job); err != nil {
+			return fmt.Errorf("invalid initial run for job %q: %v", job.Name, err)
+		}
+
+		if err := checkCollections(&job); err != nil {
+			return fmt.Errorf("invalid collections for job %q: %v", job.Name, err)
+		}
+
+		if err := checkItemTypes(&job); err != nil {
+			return fmt.Errorf("invalid item types for job %q: %v", job.Name, err)
+		}
+
+		if err := checkGuards(&job); err != nil {
+			return fmt.Errorf("invalid guards for job %q: %v", job.Name, err)
+		}
+
+		if err := w.checkExecutor(&job); err != nil {
+			return fmt.Errorf("invalid execution settings for job %q: %v", job.Name, err)
+		}
+
+		if err := w.checkJitter(&job); err != nil {
+			return fmt.Errorf("invalid jitter for job %q: %v", job.Name, err)
+		}
+
 		if len(strings.TrimSpace(job.Name)) == 0 {
 			return fmt.Errorf("empty or invalid job name: %q", job.Name)
 		}
+
+		if err := w.checkNormalizers(&job); err != nil {
+			return fmt.Errorf("invalid normalization for job %q: %v", job.Name, err)
+		}
+
+		if _, err := newItemMatcher(job.IgnoreItems); err != nil {
+			return fmt.Errorf("invalid ignore_items for job %q: %v", job.Name, err)
+		}
+
+		if err := w.checkDigest(&job); err != nil {
+			return fmt.Errorf("invalid digest settings for job %q: %v", job.Name, err)
+		}
+
+		if err := w.checkTemplates(&job); err != nil {
+			return fmt.Errorf("invalid templates for job %q: %v", job.Name, err)
+		}
+
+		if err := checkCompare(&job); err != nil {
+			return fmt.Errorf("invalid compare settings for job %q: %v", job.Name, err)
+		}
+
+		if err := w.checkQuietWindows(&job); err != nil {
+			return fmt.Errorf("invalid quiet windows for job %q: %v", job.Name, err)
+		}
+
+		if job.ConfirmRuns < 0 {
+			return fmt.Errorf("confirm_runs of job %q must not be negative", job.Name)
+		}
 	}
 
 	return nil
@@ -55,7 +142,7 @@ func (w *Watcher) CheckConfig() error {
 // RunNow executes all jobs instantly and in series
 func (w *Watcher) RunNow() error {
 	for _, job := range w.config.Jobs {
-		if err := w.do(&job); err != nil {
+		if err := w.run(&job); err != nil {
 			return err
 		}
 	}
@@ -75,67 +162,272 @@ func (w *Watcher) AddNotifier(n notifier) error {
 	return nil
 }
 
-func (w *Watcher) do(job *api.Job) error {
+func (w *Watcher) do(ctx context.Context, job *api.Job) error {
 	w.logger.Infof("Running job %s", job.Name)
+	start := time.Now()
 
-	pmJob, err := w.executeJob(job)
+	pmJob, processed, err := w.executeJob(ctx, job)
+	if err == errNoSlot {
+		return err
+	}
 	if err != nil {
-		return fmt.Errorf("failed to execute job %q: %v", job.Name, err)
+		return &runError{kind: api.FailureExecution, err: fmt.Errorf("failed to execute job %q: %v", job.Name, err)}
 	}
 
 	if pmJob.Error != "" {
-		return fmt.Errorf("job %q of watch job %q execution failed: %v", pmJob.UUID, job.Name, pmJob.Error)
+		return &runError{kind: api.FailureExecution, err: fmt.Errorf("job %q of watch job %q execution failed: %v", pmJob.UUID, job.Name, pmJob.Error)}
+	}
+
+	run := api.Run{
+		ID:        pmJob.UUID,
+		StartedAt: start,
+		Duration:  time.Since(start),
 	}
 
+	if err := w.process(ctx, job, run, pmJob.Results); err != nil {
+		return err
+	}
+
+	return processed()
+}
+
+// process compares the results of a run with the stored values, notifies about changes and stores the new values
+func (w *Watcher) process(ctx context.Context, job *api.Job, run api.Run, results map[string]interface{}) error {
 	oldValues, err := w.getValues(job.Name)
+	firstRun := err == storage.ErrNotFound
+	if err != nil && !firstRun {
+		return w.deferOnOutage(job, run, results, fmt.Errorf("failed to load old values: %v", err))
+	}
+
+	oldHashes, err := w.getHashes(job)
+	if err != nil {
+		return w.deferOnOutage(job, run, results, err)
+	}
+
+	// results of a run deferred earlier are outdated now
+	w.dropDeferred(job.Name)
+
+	expanded, err := expandCollections(job, results)
+	if err != nil {
+		return &runError{kind: api.FailureInvalid, err: fmt.Errorf("invalid results of job %q, keeping previous values: %v", job.Name, err)}
+	}
+
+	values := w.transformResults(expanded)
+	suspected := guardValues(job, values)
+	if suspected != nil {
+		if err := w.suspect(job, values, suspected); err != nil {
+			return err
+		}
+	}
+
+	warnings := w.validateValues(job, values)
+	if len(warnings) > 0 {
+		if job.OnInvalid != onInvalidFlag {
+			return &runError{kind: api.FailureInvalid, err: fmt.Errorf("invalid results of job %q, keeping previous values: %s", job.Name, strings.Join(warnings, ", "))}
+		}
+		w.logger.Warnf("Invalid results of job %q: %s", job.Name, strings.Join(warnings, ", "))
+	}
+
+	newValues, newHashes, err := w.hashValues(job, values)
 	if err != nil {
-		return fmt.Errorf("failed to load old values: %v", err)
+		return fmt.Errorf("failed to hash values: %v", err)
+	}
+	hashes := mergeHashes(oldHashes, newHashes)
+
+	diff, err := w.diff(job, newValues, oldValues, hashes)
+	if err != nil {
+		return fmt.Errorf("failed to compare values: %v", err)
+	}
+
+	// results accepted despite a failed guard are not checked again
+	if suspected == nil {
+		if suspected = guardDiff(job, diff, newValues, oldValues); suspected != nil {
+			if err := w.suspect(job, values, suspected); err != nil {
+				return err
+			}
+		}
+	}
+
+	initial := baselineOnly(job, firstRun)
+
+	var pending map[string]pendingValue
+	if job.ConfirmRuns > 1 && !initial {
+		diff, newValues, pending, err = w.confirmChanges(job, diff, newValues, oldValues, hashes)
+		if err != nil {
+			return w.deferOnOutage(job, run, results, fmt.Errorf("failed to confirm changes: %v", err))
+		}
+	}
+
+	// a run abandoned after its timeout must not notify or store anything anymore
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	newValues := w.transformResults(pmJob.Results)
-	diff := diff(newValues, oldValues)
-	if err := w.notify(job, diff, newValues); err != nil {
+	run.Warnings = warnings
+	if initial {
+		if err := w.notifyInitial(job, run, newValues); err != nil {
+			return err
+		}
+	} else if err := w.notify(job, run, diff, newValues); err != nil {
 		return err
 	}
 
 	w.logger.Infof("Done running job %s", job.Name)
 
-	return w.setValues(job.Name, newValues)
+	// notified changes are stored even if the timeout passed meanwhile, so they aren't notified again
+	if job.ConfirmRuns > 1 {
+		if err := w.setPending(job.Name, pending); err != nil {
+			return err
+		}
+	}
+
+	if err := w.setValues(job.Name, newValues); err != nil {
+		return err
+	}
+
+	if err := w.setHashes(job.Name, hashes, newValues, pending); err != nil {
+		return err
+	}
+
+	// the history only holds values which got stored
+	if err := w.writeHistory(job, values, newValues, newHashes); err != nil {
+		return err
+	}
+
+	return w.clearSuspect(job)
+}
+
+// diff compares the normalized or hashed values of both runs, while reporting the stored ones.
+// Ignored items are never reported.
+func (w *Watcher) diff(job *api.Job, newValues, oldValues, hashes map[string]string) ([]api.Diff, error) {
+	newCompare, err := w.compareValues(job, newValues, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	oldCompare, err := w.compareValues(job, oldValues, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := append(diff(newValues, oldValues, newCompare, oldCompare), removedItems(newValues, oldValues)...)
+	changes, err = removeIgnored(job, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	return annotateRecords(job, annotateTypes(job, changes)), nil
 }
 
-func diff(newValues, oldValues map[string]string) []api.Diff {
+func diff(newValues, oldValues, newCompare, oldCompare map[string]string) []api.Diff {
 	diff := make([]api.Diff, 0)
 
 	for key, newVal := range newValues {
 		oldVal, ok := oldValues[key]
 
-		if ok && oldVal != "" && oldVal == newVal {
+		if ok && oldVal != "" && oldCompare[key] == newCompare[key] {
 			continue
 		}
 
+		kind := api.DiffChanged
+		if !ok {
+			kind = api.DiffAdded
+		}
+
 		diff = append(diff, api.Diff{
 			Item:     key,
 			OldValue: oldVal,
 			NewValue: newVal,
+			Kind:     kind,
 		})
 	}
 
 	return diff
 }
 
-func (w *Watcher) notify(job *api.Job, diff []api.Diff, newValues map[string]string) error {
+func (w *Watcher) notify(job *api.Job, run api.Run, diff []api.Diff, newValues map[string]string) error {
 	if len(diff) == 0 && job.NotifyOnChangeOnly {
 		return nil
 	}
 
+	return w.dispatch(job, run, diff, newValues, false)
+}
+
+// dispatch passes the notification of a run to the notify entries of a job, adding it to digests or holding it
+// back during quiet windows. Notifications without changes, like the initial summary, are never added to digests.
+func (w *Watcher) dispatch(job *api.Job, run api.Run, diff []api.Diff, values map[string]string, initial bool) error {
+	now := time.Now()
+	var notifierKeys []string
+	batches := make(map[string][]api.Notification)
 	for _, notify := range job.Notify {
-		not, err := w.getNotifier(notify.Type)
+		if notify.Digest {
+			if len(diff) == 0 {
+				continue
+			}
+
+			if err := w.queueDigest(job, notify, run, diff, values); err != nil {
+				return fmt.Errorf("failed to queue digest for %q: %v", notify.Type, err)
+			}
+			continue
+		}
+
+		window, until, err := w.activeQuietWindow(job, notify, now)
 		if err != nil {
-			return err
+			return fmt.Errorf("invalid quiet window for %q: %v", notify.Type, err)
+		}
+
+		if window != nil {
+			if err := w.quiet(job, notify, window, until, run, diff, values, initial); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, ok := batches[notify.Type]; !ok {
+			notifierKeys = append(notifierKeys, notify.Type)
+		}
+
+		notification := w.newNotification(job, notify, run, diff, values)
+		notification.Initial = initial
+		batches[notify.Type] = append(batches[notify.Type], notification)
+	}
+
+	for _, key := range notifierKeys {
+		if err := w.send(key, batches[key]); err != nil {
+			return fmt.Errorf("failed to notify by %q: %v", key, err)
 		}
+	}
+
+	return nil
+}
 
-		if err := not.Notify(job.Name, notify.Value, diff, newValues); err != nil {
-			return fmt.Errorf("failed to notify by %q: %v", notify.Type, err)
+// newNotification returns the notification of a notify entry about a run
+func (w *Watcher) newNotification(job *api.Job, notify api.NotifyEntry, run api.Run, diff []api.Diff, values map[string]string) api.Notification {
+	return api.Notification{
+		Job:       job,
+		Target:    notify.Value,
+		Options:   notify.Options,
+		Diff:      diff,
+		Values:    values,
+		Run:       run,
+		Templates: w.templates(job, notify.Type),
+	}
+}
+
+// send passes notifications to a notifier, all at once if it supports batches
+func (w *Watcher) send(notifierKey string, notifications []api.Notification) error {
+	not, err := w.getNotifier(notifierKey)
+	if err != nil {
+		return err
+	}
+
+	if batch, ok := not.(batchNotifier); ok {
+		return batch.NotifyAll(notifications)
+	}
+
+	for _, n := range notifications {
+		if err := not.Notify(n); err != nil {
+			return err
 		}
 	}
 
@@ -151,24 +443,45 @@ func (w *Watcher) getNotifier(name string) (notifier, error) {
 	return not, nil
 }
 
-func (w *Watcher) cronFunc(job *api.Job) func() {
+// cronFunc returns the cron function of a job. Runs delayed by jitter are skipped once the context is done.
+func (w *Watcher) cronFunc(ctx context.Context, job *api.Job) func() {
 	return func() {
-		if err := w.do(job); err != nil {
-			w.logger.Error(err)
+		if delay := w.jitter(job); delay > 0 {
+			w.logger.Debugf("Delaying job %q by %s", job.Name, delay)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				w.logger.Debugf("Skipping delayed run of job %q, stopping", job.Name)
+				return
+			}
+		}
+
+		if err := w.run(job); err != nil {
+			w.logger.WithField("failure", failureKind(err)).Error(err)
 		}
 	}
 }
 
-// RegisterCronJobs registers all jobs taken from config at the given cron instance
-func (w *Watcher) RegisterCronJobs(cron *cron.Cron) error {
+// RegisterCronJobs registers all jobs taken from config at the given cron instance. Runs still waiting
+// for their jitter are skipped once the context is done.
+func (w *Watcher) RegisterCronJobs(ctx context.Context, c *cron.Cron) error {
 	for i, job := range w.config.Jobs {
-		w.logger.Debugf("Adding job %q with pattern %q", job.Name, job.Schedule)
-		if err := cron.AddFunc(job.Schedule, w.cronFunc(&(w.config.Jobs[i]))); err != nil {
+		w.logger.Debugf("Adding job %q with pattern %q in timezone %q", job.Name, job.Schedule, job.Timezone)
+		schedule, err := w.jobSchedule(&w.config.Jobs[i])
+		if err != nil {
 			return fmt.Errorf("failed to register cron for job %q: %v", job.Name, err)
 		}
+		c.Schedule(schedule, cron.FuncJob(w.cronFunc(ctx, &(w.config.Jobs[i]))))
 	}
 
-	return nil
+	if err := w.registerDelayed(c); err != nil {
+		return err
+	}
+
+	return w.registerDigest(c)
 }
 
 func (w *Watcher) transformResults(values map[string]interface{}) map[string]string {
@@ -0,0 +1,204 @@
+package watcher
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+const (
+	windowDrop   = "drop"
+	windowDelay  = "delay"
+	windowDigest = "digest"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// quietWindow is a parsed api.QuietWindow
+type quietWindow struct {
+	action string
+	// absolute windows
+	start, end time.Time
+	// daily windows, as minutes of the day
+	daily    bool
+	from, to int
+	days     map[time.Weekday]bool
+	location *time.Location
+}
+
+func newQuietWindow(cfg api.QuietWindow, location *time.Location) (*quietWindow, error) {
+	w := &quietWindow{action: cfg.Action}
+	if w.action == "" {
+		w.action = windowDrop
+	}
+	if w.action != windowDrop && w.action != windowDelay && w.action != windowDigest {
+		return nil, fmt.Errorf("unknown action %q", cfg.Action)
+	}
+
+	if cfg.Start != nil || cfg.End != nil {
+		if cfg.Start == nil || cfg.End == nil || !cfg.End.After(*cfg.Start) {
+			return nil, fmt.Errorf("absolute windows need a start before their end")
+		}
+		w.start, w.end = *cfg.Start, *cfg.End
+		return w, nil
+	}
+
+	var err error
+	w.daily = true
+	if w.from, err = parseTimeOfDay(cfg.From); err != nil {
+		return nil, err
+	}
+	if w.to, err = parseTimeOfDay(cfg.To); err != nil {
+		return nil, err
+	}
+
+	w.location = location
+	if cfg.Timezone != "" {
+		if w.location, err = time.LoadLocation(cfg.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %v", cfg.Timezone, err)
+		}
+	}
+
+	if len(cfg.Days) > 0 {
+		w.days = make(map[time.Weekday]bool)
+		for _, d := range cfg.Days {
+			key := strings.ToLower(d)
+			if len(key) > 3 {
+				key = key[:3]
+			}
+
+			day, ok := weekdays[key]
+			if !ok {
+				return nil, fmt.Errorf("unknown weekday %q", d)
+			}
+			w.days[day] = true
+		}
+	}
+
+	return w, nil
+}
+
+// activeUntil returns the end of the window, if it is active at the given time
+func (w *quietWindow) activeUntil(t time.Time) (time.Time, bool) {
+	if !w.daily {
+		return w.end, !t.Before(w.start) && t.Before(w.end)
+	}
+
+	t = t.In(w.location)
+	// a window over midnight may have started yesterday, so check both days
+	for _, start := range []time.Time{t.AddDate(0, 0, -1), t} {
+		if w.days != nil && !w.days[start.Weekday()] {
+			continue
+		}
+
+		from := w.timeOnDay(start, w.from)
+		to := w.timeOnDay(start, w.to)
+		if !to.After(from) {
+			to = w.timeOnDay(start.AddDate(0, 0, 1), w.to)
+		}
+
+		if !t.Before(from) && t.Before(to) {
+			return to, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (w *quietWindow) timeOnDay(day time.Time, minutes int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, w.location)
+}
+
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// quietWindows returns the parsed quiet windows of a notify entry, including the ones of its job.
+// Daily windows default to the time zone of the job.
+func (w *Watcher) quietWindows(job *api.Job, entry api.NotifyEntry) ([]*quietWindow, error) {
+	location, err := w.jobLocation(job)
+	if err != nil {
+		return nil, err
+	}
+
+	var windows []*quietWindow
+	for _, cfg := range append(append([]api.QuietWindow{}, job.QuietWindows...), entry.QuietWindows...) {
+		window, err := newQuietWindow(cfg, location)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+
+	return windows, nil
+}
+
+// activeQuietWindow returns the first quiet window of a notify entry active at the given time
+func (w *Watcher) activeQuietWindow(job *api.Job, entry api.NotifyEntry, t time.Time) (*quietWindow, time.Time, error) {
+	windows, err := w.quietWindows(job, entry)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	for _, window := range windows {
+		if until, ok := window.activeUntil(t); ok {
+			return window, until, nil
+		}
+	}
+
+	return nil, time.Time{}, nil
+}
+
+// checkQuietWindows validates the quiet windows of all notify entries of a job
+func (w *Watcher) checkQuietWindows(job *api.Job) error {
+	for _, notify := range job.Notify {
+		windows, err := w.quietWindows(job, notify)
+		if err != nil {
+			return fmt.Errorf("notify entry %q: %v", notify.Type, err)
+		}
+
+		for _, window := range windows {
+			if window.action != windowDigest {
+				continue
+			}
+
+			if err := w.checkDigestEntry(notify); err != nil {
+				return fmt.Errorf("quiet window folds into digests: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// usesDelay reports whether any quiet window delays notifications
+func (w *Watcher) usesDelay() bool {
+	for i := range w.config.Jobs {
+		job := &w.config.Jobs[i]
+		for _, notify := range job.Notify {
+			windows, _ := w.quietWindows(job, notify)
+			for _, window := range windows {
+				if window.action == windowDelay {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,130 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Scalify/website-content-watcher/pkg/api"
+)
+
+func TestQuietWindowActiveUntil(t *testing.T) {
+	// 2024-01-01 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	start, end := at(1, 10, 0), at(1, 12, 0)
+	cet := time.FixedZone("CET", 60*60)
+
+	tests := []struct {
+		name       string
+		window     api.QuietWindow
+		location   *time.Location
+		t          time.Time
+		wantActive bool
+		wantUntil  time.Time
+	}{
+		{
+			name:       "within absolute window",
+			window:     api.QuietWindow{Start: &start, End: &end},
+			t:          at(1, 11, 0),
+			wantActive: true,
+			wantUntil:  end,
+		},
+		{
+			name:   "end of absolute window",
+			window: api.QuietWindow{Start: &start, End: &end},
+			t:      end,
+		},
+		{
+			name:       "daily window",
+			window:     api.QuietWindow{From: "09:00", To: "17:00"},
+			t:          at(3, 9, 0),
+			wantActive: true,
+			wantUntil:  at(3, 17, 0),
+		},
+		{
+			name:   "outside daily window",
+			window: api.QuietWindow{From: "09:00", To: "17:00"},
+			t:      at(3, 17, 0),
+		},
+		{
+			name:       "over midnight before midnight",
+			window:     api.QuietWindow{From: "22:00", To: "06:00"},
+			t:          at(1, 23, 0),
+			wantActive: true,
+			wantUntil:  at(2, 6, 0),
+		},
+		{
+			name:       "over midnight after midnight",
+			window:     api.QuietWindow{From: "22:00", To: "06:00"},
+			t:          at(2, 5, 0),
+			wantActive: true,
+			wantUntil:  at(2, 6, 0),
+		},
+		{
+			name:       "started on an included day",
+			window:     api.QuietWindow{From: "22:00", To: "06:00", Days: []string{"Monday"}},
+			t:          at(2, 5, 0),
+			wantActive: true,
+			wantUntil:  at(2, 6, 0),
+		},
+		{
+			name:   "started on an excluded day",
+			window: api.QuietWindow{From: "22:00", To: "06:00", Days: []string{"mon"}},
+			t:      at(1, 5, 0),
+		},
+		{
+			name:       "time zone of the job",
+			window:     api.QuietWindow{From: "09:00", To: "10:00"},
+			location:   cet,
+			t:          at(1, 8, 30),
+			wantActive: true,
+			wantUntil:  time.Date(2024, 1, 1, 10, 0, 0, 0, cet),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location := tt.location
+			if location == nil {
+				location = time.UTC
+			}
+
+			w, err := newQuietWindow(tt.window, location)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			until, active := w.activeUntil(tt.t)
+			if active != tt.wantActive {
+				t.Fatalf("got active %v, want %v", active, tt.wantActive)
+			}
+			if active && !until.Equal(tt.wantUntil) {
+				t.Errorf("got until %v, want %v", until, tt.wantUntil)
+			}
+		})
+	}
+}
+
+func TestNewQuietWindowErrors(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		window api.QuietWindow
+	}{
+		{name: "unknown action", window: api.QuietWindow{From: "22:00", To: "06:00", Action: "mute"}},
+		{name: "missing end", window: api.QuietWindow{Start: &start}},
+		{name: "end before start", window: api.QuietWindow{Start: &start, End: &time.Time{}}},
+		{name: "invalid time of day", window: api.QuietWindow{From: "10pm", To: "06:00"}},
+		{name: "unknown weekday", window: api.QuietWindow{From: "22:00", To: "06:00", Days: []string{"someday"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newQuietWindow(tt.window, time.UTC); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}